@@ -0,0 +1,242 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every deployment-specific setting. Values come from defaults,
+// then the optional YAML file, then environment variables, in that order.
+type Config struct {
+	Port        int           `yaml:"port"`
+	PublicURL   string        `yaml:"public_url"` // base URL the API is reached at, used for upload links
+	DatabaseDSN string        `yaml:"database_dsn"`
+	JWTSecret   string        `yaml:"jwt_secret"`
+	TokenTTL    time.Duration `yaml:"token_ttl"`   // lifetime of an access token
+	RefreshTTL  time.Duration `yaml:"refresh_ttl"` // how long a session lasts without signing in again
+	CORSOrigins []string      `yaml:"cors_origins"`
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For is believed. None by default, so the client IP
+	// used for login throttling can't be forged with a header.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	CookieDomain   string   `yaml:"cookie_domain"`
+	CookieSecure   bool     `yaml:"cookie_secure"`
+	UploadDir      string   `yaml:"upload_dir"`
+	// CheckinWindow is how long a class check-in QR code stays valid.
+	CheckinWindow time.Duration `yaml:"checkin_window"`
+	// FrontendURL is where links in emails (password resets) point.
+	FrontendURL  string `yaml:"frontend_url"`
+	MailDriver   string `yaml:"mail_driver"` // log, file or smtp
+	MailFrom     string `yaml:"mail_from"`
+	MailDir      string `yaml:"mail_dir"` // used by the file driver
+	SMTPAddr     string `yaml:"smtp_addr"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+}
+
+// App is the configuration loaded at startup.
+var App *Config
+
+// MustLoad loads and validates the configuration, panicking on failure so the
+// server never starts half-configured.
+func MustLoad() {
+	cfg, err := Load()
+	if err != nil {
+		panic("❌ Invalid configuration: " + err.Error())
+	}
+	App = cfg
+}
+
+// Load reads the file named by CONFIG_FILE (default config.yaml, skipped when
+// absent) and applies environment overrides on top.
+func Load() (*Config, error) {
+	cfg := &Config{
+		Port:          8080,
+		PublicURL:     "http://localhost:8080",
+		TokenTTL:      15 * time.Minute,
+		RefreshTTL:    30 * 24 * time.Hour,
+		CORSOrigins:   []string{"http://localhost:3000"},
+		CookieDomain:  "localhost",
+		UploadDir:     "uploads",
+		CheckinWindow: 5 * time.Minute,
+		FrontendURL:   "http://localhost:3000",
+		MailDriver:    "log",
+		MailFrom:      "no-reply@localhost",
+		MailDir:       "outbox",
+	}
+
+	path := os.Getenv("CONFIG_FILE")
+	explicit := path != ""
+	if !explicit {
+		path = "config.yaml"
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) applyEnv() error {
+	if v := os.Getenv("PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("PORT: %w", err)
+		}
+		cfg.Port = port
+	}
+	if v := os.Getenv("PUBLIC_URL"); v != "" {
+		cfg.PublicURL = v
+	}
+	if v := os.Getenv("DATABASE_DSN"); v != "" {
+		cfg.DatabaseDSN = v
+	}
+	if v := os.Getenv("JWT_SECRET"); v != "" {
+		cfg.JWTSecret = v
+	}
+	if v := os.Getenv("TOKEN_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("TOKEN_TTL: %w", err)
+		}
+		cfg.TokenTTL = ttl
+	}
+	if v := os.Getenv("REFRESH_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("REFRESH_TTL: %w", err)
+		}
+		cfg.RefreshTTL = ttl
+	}
+	if v := os.Getenv("CORS_ORIGINS"); v != "" {
+		cfg.CORSOrigins = nil
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.CORSOrigins = append(cfg.CORSOrigins, origin)
+			}
+		}
+	}
+	if v, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		cfg.TrustedProxies = nil
+		for _, proxy := range strings.Split(v, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+			}
+		}
+	}
+	if v, ok := os.LookupEnv("COOKIE_DOMAIN"); ok {
+		cfg.CookieDomain = v
+	}
+	if v := os.Getenv("COOKIE_SECURE"); v != "" {
+		secure, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("COOKIE_SECURE: %w", err)
+		}
+		cfg.CookieSecure = secure
+	}
+	if v := os.Getenv("UPLOAD_DIR"); v != "" {
+		cfg.UploadDir = v
+	}
+	if v := os.Getenv("CHECKIN_WINDOW"); v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("CHECKIN_WINDOW: %w", err)
+		}
+		cfg.CheckinWindow = window
+	}
+	if v := os.Getenv("FRONTEND_URL"); v != "" {
+		cfg.FrontendURL = v
+	}
+	if v := os.Getenv("MAIL_DRIVER"); v != "" {
+		cfg.MailDriver = v
+	}
+	if v := os.Getenv("MAIL_FROM"); v != "" {
+		cfg.MailFrom = v
+	}
+	if v := os.Getenv("MAIL_DIR"); v != "" {
+		cfg.MailDir = v
+	}
+	if v := os.Getenv("SMTP_ADDR"); v != "" {
+		cfg.SMTPAddr = v
+	}
+	if v := os.Getenv("SMTP_USERNAME"); v != "" {
+		cfg.SMTPUsername = v
+	}
+	if v := os.Getenv("SMTP_PASSWORD"); v != "" {
+		cfg.SMTPPassword = v
+	}
+	return nil
+}
+
+// Validate reports every problem at once rather than stopping at the first.
+func (cfg *Config) Validate() error {
+	var problems []string
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		problems = append(problems, "port must be between 1 and 65535")
+	}
+	if cfg.DatabaseDSN == "" {
+		problems = append(problems, "database_dsn (DATABASE_DSN) is required")
+	}
+	if len(cfg.JWTSecret) < 16 {
+		problems = append(problems, "jwt_secret (JWT_SECRET) must be at least 16 characters")
+	}
+	if cfg.TokenTTL <= 0 {
+		problems = append(problems, "token_ttl must be positive")
+	}
+	if cfg.RefreshTTL <= cfg.TokenTTL {
+		problems = append(problems, "refresh_ttl must be longer than token_ttl")
+	}
+	if len(cfg.CORSOrigins) == 0 {
+		problems = append(problems, "cors_origins needs at least one origin")
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			problems = append(problems, fmt.Sprintf("trusted_proxies: %q is not an IP address or CIDR range", proxy))
+		}
+	}
+	if !strings.HasPrefix(cfg.PublicURL, "http://") && !strings.HasPrefix(cfg.PublicURL, "https://") {
+		problems = append(problems, "public_url must start with http:// or https://")
+	}
+	if cfg.UploadDir == "" {
+		problems = append(problems, "upload_dir must not be empty")
+	}
+	if cfg.CheckinWindow <= 0 {
+		problems = append(problems, "checkin_window must be positive")
+	}
+	if !strings.HasPrefix(cfg.FrontendURL, "http://") && !strings.HasPrefix(cfg.FrontendURL, "https://") {
+		problems = append(problems, "frontend_url must start with http:// or https://")
+	}
+	switch cfg.MailDriver {
+	case "log", "file", "smtp":
+	default:
+		problems = append(problems, "mail_driver must be log, file or smtp")
+	}
+	if cfg.MailDriver == "smtp" && cfg.SMTPAddr == "" {
+		problems = append(problems, "smtp_addr (SMTP_ADDR) is required for the smtp mail driver")
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	cfg.PublicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	cfg.FrontendURL = strings.TrimSuffix(cfg.FrontendURL, "/")
+	return nil
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/models"
+)
+
+const (
+	ConflictTeacher  = "teacher"
+	ConflictVenue    = "venue"
+	ConflictStudents = "students"
+)
+
+// ScheduleConflict is an existing slot that overlaps the one being saved.
+type ScheduleConflict struct {
+	ScheduleID     int    `json:"schedule_id"`
+	Type           string `json:"type"`
+	CourseID       int    `json:"course_id"`
+	CourseCode     string `json:"course_code"`
+	TeacherID      int    `json:"teacher_id"`
+	DayOfWeek      string `json:"day_of_week"`
+	StartTime      string `json:"start_time"`
+	EndTime        string `json:"end_time"`
+	Venue          string `json:"venue"`
+	SharedStudents int    `json:"shared_students,omitempty"`
+}
+
+// normalizeClock turns "9:00", "09:00" or "09:00:00" into "09:00:00" so times
+// compare correctly as strings and match MySQL's TIME format.
+func normalizeClock(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("15:04:05"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid time %q", value)
+}
+
+// findScheduleConflicts lists every slot in the same school, day and term
+// that overlaps s and shares its teacher, its venue, or any enrolled student.
+// excludeID skips the slot being updated. s.StartTime and s.EndTime must
+// already be normalised.
+func findScheduleConflicts(s models.ClassSchedule, excludeID uint) ([]ScheduleConflict, error) {
+	rows, err := database.DB.Query(`
+        SELECT cs.id, cs.teacher_id, cs.course_id, c.code, cs.day_of_week,
+               cs.start_time, cs.end_time, cs.venue
+        FROM class_schedules cs
+        JOIN courses c ON cs.course_id = c.id
+        WHERE cs.school_id = ? AND cs.day_of_week = ? AND cs.term_id = ? AND cs.id <> ?
+          AND cs.start_time < ? AND cs.end_time > ?
+    `, s.SchoolID, s.DayOfWeek, s.TermID, excludeID, s.EndTime, s.StartTime)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlapping []ScheduleConflict
+	for rows.Next() {
+		var o ScheduleConflict
+		if err := rows.Scan(&o.ScheduleID, &o.TeacherID, &o.CourseID, &o.CourseCode, &o.DayOfWeek,
+			&o.StartTime, &o.EndTime, &o.Venue); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		overlapping = append(overlapping, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var conflicts []ScheduleConflict
+	for _, o := range overlapping {
+		if o.TeacherID == s.TeacherID {
+			o.Type = ConflictTeacher
+			conflicts = append(conflicts, o)
+		}
+		if strings.EqualFold(strings.TrimSpace(o.Venue), strings.TrimSpace(s.Venue)) {
+			o.Type = ConflictVenue
+			conflicts = append(conflicts, o)
+		}
+
+		var shared int
+		err := database.DB.QueryRow(`
+            SELECT COUNT(DISTINCT a.student_id)
+            FROM student_courses a
+            JOIN student_courses b ON a.student_id = b.student_id AND a.term_id = b.term_id
+            WHERE a.course_id = ? AND b.course_id = ? AND a.term_id = ?
+        `, s.CourseID, o.CourseID, s.TermID).Scan(&shared)
+		if err != nil {
+			return nil, err
+		}
+		if shared > 0 {
+			o.Type = ConflictStudents
+			o.SharedStudents = shared
+			conflicts = append(conflicts, o)
+		}
+	}
+	return conflicts, nil
+}
@@ -2,36 +2,37 @@ package controllers
 
 import (
 
+	"database/sql"
 	"net/http"
 	"school-backend/database"
+	"school-backend/middleware"
 	"school-backend/models"
 	"strconv"
+	"strings"
 	"github.com/gin-gonic/gin"
 )
 
 func GetTeacherSchedule(c *gin.Context) {
-    teacherID := c.Param("id")
-    tid, err := strconv.Atoi(teacherID)
-    if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid teacher ID"})
+    tid, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+    if !ok {
         return
     }
 
-    // ✅ Ensure teacher belongs to a school
-    var schoolID int
-    err = database.DB.QueryRow("SELECT school_id FROM teachers WHERE id = ?", tid).Scan(&schoolID)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch teacher’s school"})
+    // ✅ SubjectID already checked the teacher belongs to the caller's school
+    schoolID := middleware.CurrentUser(c).SchoolID
+    scope, ok := middleware.RequestedTerm(c)
+    if !ok {
         return
     }
+    inTerm, termArgs := scope.Where("cs.term_id")
 
     rows, err := database.DB.Query(`
         SELECT cs.id, cs.course_id, cs.day_of_week, cs.start_time, cs.end_time,
-               cs.venue, cs.semester, cs.created_at, c.code, c.name
+               cs.venue, cs.semester, cs.term_id, cs.created_at, c.code, c.name
         FROM class_schedules cs
         JOIN courses c ON cs.course_id = c.id
-        WHERE cs.teacher_id = ? AND cs.school_id = ?
-    `, tid, schoolID)
+        WHERE cs.teacher_id = ? AND cs.school_id = ? AND `+inTerm+`
+    `, append([]interface{}{tid, schoolID}, termArgs...)...)
 
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -45,7 +46,7 @@ func GetTeacherSchedule(c *gin.Context) {
         var courseCode, courseName string
         err := rows.Scan(
             &s.ID, &s.CourseID, &s.DayOfWeek, &s.StartTime, &s.EndTime,
-            &s.Venue, &s.Semester, &s.CreatedAt, &courseCode, &courseName,
+            &s.Venue, &s.Semester, &s.TermID, &s.CreatedAt, &courseCode, &courseName,
         )
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -63,10 +64,8 @@ func GetTeacherSchedule(c *gin.Context) {
 
 
 func AddClassSchedule(c *gin.Context) {
-    teacherID := c.Param("id")
-    tid, err := strconv.Atoi(teacherID)
-    if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid teacher ID"})
+    tid, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+    if !ok {
         return
     }
 
@@ -76,27 +75,238 @@ func AddClassSchedule(c *gin.Context) {
         return
     }
 
-    // ✅ Get teacher’s school_id
-    var schoolID int
-    err = database.DB.QueryRow("SELECT school_id FROM teachers WHERE id = ?", tid).Scan(&schoolID)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch teacher’s school"})
+    // ✅ Schedules live in the caller's school
+    schoolID := middleware.CurrentUser(c).SchoolID
+    input.TeacherID = tid
+    input.SchoolID = schoolID
+
+    conflicts, ok := checkSchedule(c, &input, 0)
+    if !ok {
         return
     }
 
     query := `
-        INSERT INTO class_schedules (teacher_id, course_id, day_of_week, start_time, end_time, venue, semester, school_id)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+        INSERT INTO class_schedules (teacher_id, course_id, day_of_week, start_time, end_time, venue, semester, term_id, school_id)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
     `
-    _, err = database.DB.Exec(query,
+    _, err := database.DB.Exec(query,
         tid, input.CourseID, input.DayOfWeek,
-        input.StartTime, input.EndTime, input.Venue, input.Semester, schoolID)
+        input.StartTime, input.EndTime, input.Venue, input.Semester, input.TermID, schoolID)
 
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
         return
     }
 
+    if len(conflicts) > 0 {
+        c.JSON(http.StatusOK, gin.H{"message": "Schedule created with conflicts", "warnings": conflicts})
+        return
+    }
     c.JSON(http.StatusOK, gin.H{"message": "Schedule created successfully"})
 }
 
+
+func UpdateClassSchedule(c *gin.Context) {
+    tid, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+    if !ok {
+        return
+    }
+    scheduleID, err := strconv.Atoi(c.Param("scheduleId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+        return
+    }
+
+    var input models.ClassSchedule
+    if err := c.BindJSON(&input); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+        return
+    }
+
+    schoolID := middleware.CurrentUser(c).SchoolID
+    if !ownsSchedule(c, scheduleID, tid, schoolID) {
+        return
+    }
+    input.TeacherID = tid
+    input.SchoolID = schoolID
+
+    conflicts, ok := checkSchedule(c, &input, uint(scheduleID))
+    if !ok {
+        return
+    }
+
+    _, err = database.DB.Exec(`
+        UPDATE class_schedules
+        SET course_id = ?, day_of_week = ?, start_time = ?, end_time = ?, venue = ?, semester = ?, term_id = ?
+        WHERE id = ? AND teacher_id = ? AND school_id = ?
+    `, input.CourseID, input.DayOfWeek, input.StartTime, input.EndTime, input.Venue, input.Semester, input.TermID,
+        scheduleID, tid, schoolID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+        return
+    }
+
+    if len(conflicts) > 0 {
+        c.JSON(http.StatusOK, gin.H{"message": "Schedule updated with conflicts", "warnings": conflicts})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Schedule updated successfully"})
+}
+
+
+func DeleteClassSchedule(c *gin.Context) {
+    tid, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+    if !ok {
+        return
+    }
+    scheduleID, err := strconv.Atoi(c.Param("scheduleId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+        return
+    }
+
+    schoolID := middleware.CurrentUser(c).SchoolID
+    if !ownsSchedule(c, scheduleID, tid, schoolID) {
+        return
+    }
+
+    _, err = database.DB.Exec(
+        "DELETE FROM class_schedules WHERE id = ? AND teacher_id = ? AND school_id = ?",
+        scheduleID, tid, schoolID,
+    )
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted successfully"})
+}
+
+
+// ownsSchedule reports a slot belonging to another teacher or school as missing.
+func ownsSchedule(c *gin.Context, scheduleID, teacherID, schoolID int) bool {
+    var exists bool
+    err := database.DB.QueryRow(
+        "SELECT EXISTS (SELECT 1 FROM class_schedules WHERE id = ? AND teacher_id = ? AND school_id = ?)",
+        scheduleID, teacherID, schoolID,
+    ).Scan(&exists)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
+        return false
+    }
+    if !exists {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+        return false
+    }
+    return true
+}
+
+
+// resolveScheduleTerm ties the slot to a term: the term_id given, else the
+// term named by semester, else the current term. A semester that names no
+// term is kept as free text with no term.
+func resolveScheduleTerm(c *gin.Context, input *models.ClassSchedule) bool {
+    var err error
+    switch {
+    case input.TermID != 0:
+        if !middleware.InSchool(c, middleware.Tenant(c).Term(input.TermID), "Term") {
+            return false
+        }
+        err = database.DB.QueryRow("SELECT name FROM semesters WHERE id = ?", input.TermID).Scan(&input.Semester)
+    case input.Semester != "":
+        err = database.DB.QueryRow(
+            "SELECT id FROM semesters WHERE school_id = ? AND name = ?", input.SchoolID, input.Semester,
+        ).Scan(&input.TermID)
+        if err == sql.ErrNoRows {
+            err = nil
+        }
+    default:
+        input.TermID, err = database.CurrentTermID(database.DB, input.SchoolID)
+        if err == nil && input.TermID != 0 {
+            err = database.DB.QueryRow("SELECT name FROM semesters WHERE id = ?", input.TermID).Scan(&input.Semester)
+        }
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch term"})
+        return false
+    }
+    return true
+}
+
+
+var weekdays = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+// checkSchedule validates a slot before it is saved and runs conflict
+// detection. It writes the error response itself; on success it returns the
+// conflicts that were overridden with ?force=true so they can be reported.
+func checkSchedule(c *gin.Context, input *models.ClassSchedule, excludeID uint) ([]ScheduleConflict, bool) {
+    // ✅ Weekday names are stored capitalised ("Monday")
+    day := ""
+    for _, d := range weekdays {
+        if strings.EqualFold(strings.TrimSpace(input.DayOfWeek), d) {
+            day = d
+        }
+    }
+    if day == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "day_of_week must be a weekday name such as Monday"})
+        return nil, false
+    }
+    input.DayOfWeek = day
+
+    var err error
+    if input.StartTime, err = normalizeClock(input.StartTime); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return nil, false
+    }
+    if input.EndTime, err = normalizeClock(input.EndTime); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return nil, false
+    }
+    if input.EndTime <= input.StartTime {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "end_time must be after start_time"})
+        return nil, false
+    }
+
+    input.Venue = strings.TrimSpace(input.Venue)
+    input.Semester = strings.TrimSpace(input.Semester)
+    if !resolveScheduleTerm(c, input) {
+        return nil, false
+    }
+    if input.Venue == "" || input.Semester == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "venue and semester are required"})
+        return nil, false
+    }
+
+    // ✅ Only the course's own teachers can timetable it
+    if !middleware.InSchool(c, middleware.Tenant(c).Course(input.CourseID), "Course") {
+        return nil, false
+    }
+    var teaches bool
+    err = database.DB.QueryRow(
+        "SELECT EXISTS (SELECT 1 FROM teacher_courses WHERE teacher_id = ? AND course_id = ?)",
+        input.TeacherID, input.CourseID,
+    ).Scan(&teaches)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check course assignment"})
+        return nil, false
+    }
+    if !teaches {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Teacher is not assigned to this course"})
+        return nil, false
+    }
+
+    // ✅ Refuse overlapping slots unless the caller explicitly forces them
+    conflicts, err := findScheduleConflicts(*input, excludeID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check schedule conflicts"})
+        return nil, false
+    }
+    if len(conflicts) > 0 && c.Query("force") != "true" {
+        c.JSON(http.StatusConflict, gin.H{
+            "error":     "Schedule conflicts with existing classes; retry with ?force=true to save anyway",
+            "conflicts": conflicts,
+        })
+        return nil, false
+    }
+    return conflicts, true
+}
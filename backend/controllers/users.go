@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"school-backend/database"
+	"school-backend/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +20,11 @@ type UserAccount struct {
 func GetAllTeachersDetailed(c *gin.Context) {
     schoolSlug := c.Param("slug")
     log.Printf("[DEBUG] Entered GetAllTeachersDetailed, slug=%s", schoolSlug)
+    scope, ok := middleware.RequestedTerm(c)
+    if !ok {
+        return
+    }
+    inTerm, termArgs := scope.Where("sc.term_id")
 
     query := `
     SELECT t.id, t.fullname, t.department, 
@@ -28,14 +34,14 @@ func GetAllTeachersDetailed(c *gin.Context) {
     INNER JOIN schools s ON t.school_id = s.id
     LEFT JOIN teacher_courses tc ON t.id = tc.teacher_id
     LEFT JOIN courses c ON tc.course_id = c.id
-    LEFT JOIN student_courses sc ON c.id = sc.course_id
+    LEFT JOIN student_courses sc ON c.id = sc.course_id AND ` + inTerm + `
     WHERE s.slug = ?
     GROUP BY t.id, t.fullname, t.department, c.name, c.code
     ORDER BY t.fullname ASC`
 
     log.Printf("[DEBUG] Running query: %s", query)
 
-    rows, err := database.DB.Query(query, schoolSlug)
+    rows, err := database.DB.Query(query, append(termArgs, schoolSlug)...)
     if err != nil {
         log.Printf("[ERROR] Query failed for slug=%s: %v", schoolSlug, err)
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch teacher data"})
@@ -120,18 +126,23 @@ func GetAllTeachersDetailed(c *gin.Context) {
 
 func GetAllStudentsDetailed(c *gin.Context) {
     schoolSlug := c.Param("slug") // assuming route: /api/:school_slug/students
+    scope, ok := middleware.RequestedTerm(c)
+    if !ok {
+        return
+    }
+    inTerm, termArgs := scope.Where("sc.term_id")
 
     query := `
     SELECT s.id, s.fullname, s.department,
            c.name AS course_name, c.code
     FROM students s
     INNER JOIN schools scs ON s.school_id = scs.id
-    LEFT JOIN student_courses sc ON s.id = sc.student_id
+    LEFT JOIN student_courses sc ON s.id = sc.student_id AND ` + inTerm + `
     LEFT JOIN courses c ON sc.course_id = c.id
     WHERE scs.slug = ?
     ORDER BY s.fullname ASC`
 
-    rows, err := database.DB.Query(query, schoolSlug)
+    rows, err := database.DB.Query(query, append(termArgs, schoolSlug)...)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch student data"})
         return
@@ -3,6 +3,7 @@ package database
 import (
     "database/sql"
     "fmt"
+    "school-backend/config"
    _ "github.com/go-sql-driver/mysql"
 )
 
@@ -12,9 +13,7 @@ func Connect() {
     var err error
     
 
-    dsn := "root:bankaitensa13/@tcp(127.0.0.1:3306)/school_db?parseTime=true"
-
-    DB,err = sql.Open("mysql", dsn)
+    DB,err = sql.Open("mysql", config.App.DatabaseDSN)
 
     if err != nil {
         panic("Failed to open database connection: " + err.Error())
@@ -26,7 +25,6 @@ func Connect() {
     }
 
     fmt.Println("✅ Connected to MySQL!")
-    Migrate()
 }
 
 
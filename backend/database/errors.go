@@ -0,0 +1,13 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// IsDuplicate reports whether err is a MySQL unique-key violation.
+func IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
@@ -1,25 +1,217 @@
 package database
 
-import "fmt"
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
 
+// Migrations live in migrations/ as NNNN_name.up.sql / NNNN_name.down.sql.
+// Applied versions are recorded in schema_migrations.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is one row of `migrate status`.
+type MigrationState struct {
+	Version int
+	Name    string
+	Applied bool
+}
+
+// Migrate applies every pending migration and panics if one fails, so the
+// server never starts against a half-migrated schema.
 func Migrate() {
-    query := `
-    CREATE TABLE IF NOT EXISTS class_schedules (
-        id INT AUTO_INCREMENT PRIMARY KEY,
-        teacher_id INT NOT NULL,
-        course_id INT NOT NULL,
-        day_of_week VARCHAR(10) NOT NULL,
-        start_time TIME NOT NULL,
-        end_time TIME NOT NULL,
-        venue VARCHAR(100) NOT NULL,
-        semester VARCHAR(50) NOT NULL,
-        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-    );`
-
-    _, err := DB.Exec(query)
-    if err != nil {
-        panic("❌ Migration failed: " + err.Error())
-    }
-
-    fmt.Println("✅ Table 'class_schedules' migrated")
+	n, err := MigrateUp()
+	if err != nil {
+		panic("❌ Migration failed: " + err.Error())
+	}
+	fmt.Printf("✅ Schema up to date (%d migration(s) applied)\n", n)
+}
+
+// MigrateUp applies pending migrations in order and returns how many ran.
+func MigrateUp() (int, error) {
+	migrations, applied, err := loadMigrationState()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		// MySQL commits DDL implicitly, so a failing migration can't be rolled
+		// back as a whole; its version stays unrecorded and it re-runs next time.
+		if err := execStatements(m.Up); err != nil {
+			return count, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if _, err := DB.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+			return count, fmt.Errorf("record migration %04d: %w", m.Version, err)
+		}
+		fmt.Printf("⬆️  Applied migration %04d_%s\n", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown rolls back the most recent `steps` applied migrations.
+func MigrateDown(steps int) (int, error) {
+	migrations, applied, err := loadMigrationState()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if !applied[m.Version] {
+			continue
+		}
+		if err := execStatements(m.Down); err != nil {
+			return count, fmt.Errorf("rollback %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if _, err := DB.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+			return count, fmt.Errorf("unrecord migration %04d: %w", m.Version, err)
+		}
+		fmt.Printf("⬇️  Rolled back migration %04d_%s\n", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// MigrationStatus lists every known migration and whether it has been applied.
+func MigrationStatus() ([]MigrationState, error) {
+	migrations, applied, err := loadMigrationState()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		states = append(states, MigrationState{Version: m.Version, Name: m.Name, Applied: applied[m.Version]})
+	}
+	return states, nil
+}
+
+func loadMigrationState() ([]migration, map[int]bool, error) {
+	_, err := DB.Exec(`
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INT PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    )`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := DB.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, nil, err
+		}
+		applied[v] = true
+	}
+	return migrations, applied, rows.Err()
+}
+
+func loadMigrations() ([]migration, error) {
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, file := range files {
+		base := strings.TrimPrefix(file, "migrations/")
+		direction := ""
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction, base = "up", strings.TrimSuffix(base, ".up.sql")
+		case strings.HasSuffix(base, ".down.sql"):
+			direction, base = "down", strings.TrimSuffix(base, ".down.sql")
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql", file)
+		}
+
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name", file)
+		}
+
+		body, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s: missing up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// execStatements runs a migration file one statement at a time; the MySQL
+// driver rejects multi-statement Exec unless the DSN opts in. Statements end
+// with a semicolon at the end of a line.
+func execStatements(script string) error {
+	var stmt strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		stmt.WriteString(line)
+		stmt.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			if _, err := DB.Exec(stmt.String()); err != nil {
+				return err
+			}
+			stmt.Reset()
+		}
+	}
+	if strings.TrimSpace(stmt.String()) != "" {
+		_, err := DB.Exec(stmt.String())
+		return err
+	}
+	return nil
 }
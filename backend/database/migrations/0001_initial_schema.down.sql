@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS class_schedules;
+DROP TABLE IF EXISTS cats;
+DROP TABLE IF EXISTS student_courses;
+DROP TABLE IF EXISTS teacher_courses;
+DROP TABLE IF EXISTS teachers;
+DROP TABLE IF EXISTS students;
+DROP TABLE IF EXISTS courses;
+DROP TABLE IF EXISTS departments;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS schools;
@@ -0,0 +1,4 @@
+ALTER TABLE courses
+    DROP COLUMN active,
+    DROP COLUMN description,
+    DROP COLUMN credit_units;
@@ -0,0 +1,4 @@
+ALTER TABLE courses
+    ADD COLUMN credit_units INT NOT NULL DEFAULT 0,
+    ADD COLUMN description VARCHAR(1000) NOT NULL DEFAULT '',
+    ADD COLUMN active TINYINT(1) NOT NULL DEFAULT 1;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS cat_marks;
+
+ALTER TABLE cats
+    DROP COLUMN published_at,
+    DROP COLUMN status,
+    DROP COLUMN max_score;
@@ -0,0 +1,18 @@
+ALTER TABLE cats
+    ADD COLUMN max_score DECIMAL(6,2) NOT NULL DEFAULT 100,
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    ADD COLUMN published_at DATETIME NULL;
+
+CREATE TABLE IF NOT EXISTS cat_marks (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    cat_id INT NOT NULL,
+    student_id INT NOT NULL,
+    score DECIMAL(6,2) NOT NULL,
+    recorded_by INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_cat_marks (cat_id, student_id),
+    KEY idx_cat_marks_student (student_id),
+    CONSTRAINT fk_cat_marks_cat FOREIGN KEY (cat_id) REFERENCES cats (id) ON DELETE CASCADE,
+    CONSTRAINT fk_cat_marks_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS calendar_tokens;
+DROP TABLE IF EXISTS semesters;
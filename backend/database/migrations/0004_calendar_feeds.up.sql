@@ -0,0 +1,25 @@
+-- Dates for the free-text semester names used by class_schedules.semester,
+-- so weekly classes can be bounded in calendar feeds.
+CREATE TABLE IF NOT EXISTS semesters (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_semesters_school_name (school_id, name),
+    CONSTRAINT fk_semesters_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Secret tokens that let calendar apps fetch a user's timetable without a cookie.
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    owner_role VARCHAR(20) NOT NULL,
+    owner_id INT NOT NULL,
+    token CHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_calendar_tokens_token (token),
+    UNIQUE KEY uq_calendar_tokens_owner (owner_role, owner_id),
+    CONSTRAINT fk_calendar_tokens_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
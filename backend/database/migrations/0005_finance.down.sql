@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS fee_structures;
+
+ALTER TABLE users
+    DROP COLUMN fullname;
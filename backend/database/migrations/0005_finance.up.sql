@@ -0,0 +1,53 @@
+ALTER TABLE users
+    ADD COLUMN fullname VARCHAR(255) NOT NULL DEFAULT '';
+
+-- A fee charged to every student of a department and year in a semester.
+CREATE TABLE IF NOT EXISTS fee_structures (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    department_id INT NOT NULL,
+    year VARCHAR(20) NOT NULL,
+    semester VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    due_date DATE NOT NULL,
+    created_by INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_fee_structures (school_id, department_id, year, semester, name),
+    CONSTRAINT fk_fee_structures_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE,
+    CONSTRAINT fk_fee_structures_department FOREIGN KEY (department_id) REFERENCES departments (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    student_id INT NOT NULL,
+    fee_structure_id INT NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    due_date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_invoices_student_fee (student_id, fee_structure_id),
+    KEY idx_invoices_school_due (school_id, due_date),
+    CONSTRAINT fk_invoices_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE,
+    CONSTRAINT fk_invoices_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE,
+    CONSTRAINT fk_invoices_fee_structure FOREIGN KEY (fee_structure_id) REFERENCES fee_structures (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS payments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    student_id INT NOT NULL,
+    invoice_id INT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    method VARCHAR(30) NOT NULL,
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    paid_on DATE NOT NULL,
+    recorded_by INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_payments_student (student_id),
+    KEY idx_payments_school (school_id, paid_on),
+    CONSTRAINT fk_payments_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE,
+    CONSTRAINT fk_payments_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE,
+    CONSTRAINT fk_payments_invoice FOREIGN KEY (invoice_id) REFERENCES invoices (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
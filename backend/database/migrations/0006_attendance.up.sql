@@ -0,0 +1,31 @@
+-- One meeting of a scheduled class on a given date. Course and teacher are
+-- copied from the slot so history survives the slot being deleted.
+CREATE TABLE IF NOT EXISTS attendance_sessions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    schedule_id INT NULL,
+    course_id INT NOT NULL,
+    teacher_id INT NOT NULL,
+    session_date DATE NOT NULL,
+    opened_by INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_attendance_sessions_slot (schedule_id, session_date),
+    KEY idx_attendance_sessions_course (course_id, session_date),
+    CONSTRAINT fk_attendance_sessions_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE,
+    CONSTRAINT fk_attendance_sessions_schedule FOREIGN KEY (schedule_id) REFERENCES class_schedules (id) ON DELETE SET NULL,
+    CONSTRAINT fk_attendance_sessions_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS attendance_records (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    session_id INT NOT NULL,
+    student_id INT NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    recorded_by INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_attendance_records (session_id, student_id),
+    KEY idx_attendance_records_student (student_id),
+    CONSTRAINT fk_attendance_records_session FOREIGN KEY (session_id) REFERENCES attendance_sessions (id) ON DELETE CASCADE,
+    CONSTRAINT fk_attendance_records_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+ALTER TABLE attendance_records
+    DROP COLUMN checked_in_at;
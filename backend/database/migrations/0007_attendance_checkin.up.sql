@@ -0,0 +1,3 @@
+-- Set when a student marked themselves present by scanning the class QR code.
+ALTER TABLE attendance_records
+    ADD COLUMN checked_in_at DATETIME NULL;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS password_resets;
+
+ALTER TABLE teachers
+    DROP COLUMN email;
+
+ALTER TABLE students
+    DROP COLUMN email;
@@ -0,0 +1,21 @@
+-- Students and teachers sign in by username; an email is only needed to
+-- receive password reset links.
+ALTER TABLE students
+    ADD COLUMN email VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE teachers
+    ADD COLUMN email VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Single-use reset tokens. Only a SHA-256 of the token is stored.
+-- owner_kind is the table the account lives in: student, teacher or user.
+CREATE TABLE IF NOT EXISTS password_resets (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    owner_kind VARCHAR(20) NOT NULL,
+    owner_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_password_resets_token (token_hash),
+    KEY idx_password_resets_owner (owner_kind, owner_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
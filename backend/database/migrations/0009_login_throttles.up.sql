@@ -0,0 +1,13 @@
+-- Failed sign-in counters, kept per account and per client IP. Keys exist for
+-- accounts that don't, so lockouts can't reveal which usernames are real.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    scope VARCHAR(10) NOT NULL,
+    throttle_key VARCHAR(255) NOT NULL,
+    school_id INT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME NULL,
+    UNIQUE KEY uq_login_throttles_key (scope, throttle_key),
+    KEY idx_login_throttles_school (school_id, locked_until)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,25 @@
+-- One row per signed-in device. Access tokens carry the session id and stop
+-- working once it is revoked; the refresh token rotates on every use and only
+-- its SHA-256 is stored. previous_hash catches a refresh token being replayed.
+CREATE TABLE IF NOT EXISTS sessions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    owner_kind VARCHAR(20) NOT NULL,
+    owner_id INT NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    fullname VARCHAR(255) NOT NULL DEFAULT '',
+    department VARCHAR(255) NOT NULL DEFAULT '',
+    db_slug VARCHAR(255) NOT NULL DEFAULT '',
+    refresh_hash CHAR(64) NOT NULL,
+    previous_hash CHAR(64) NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    UNIQUE KEY uq_sessions_refresh (refresh_hash),
+    KEY idx_sessions_previous (previous_hash),
+    KEY idx_sessions_owner (owner_kind, owner_id),
+    CONSTRAINT fk_sessions_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS login_challenges;
+DROP TABLE IF EXISTS two_factor_recovery_codes;
+DROP TABLE IF EXISTS two_factor;
+
+ALTER TABLE schools
+    DROP COLUMN require_admin_2fa;
@@ -0,0 +1,45 @@
+ALTER TABLE schools
+    ADD COLUMN require_admin_2fa TINYINT(1) NOT NULL DEFAULT 0;
+
+-- TOTP enrollment. enabled_at stays NULL until the first code is confirmed.
+-- last_used_step stops a code from being accepted twice.
+CREATE TABLE IF NOT EXISTS two_factor (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    owner_kind VARCHAR(20) NOT NULL,
+    owner_id INT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    enabled_at DATETIME NULL,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_two_factor_owner (owner_kind, owner_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    owner_kind VARCHAR(20) NOT NULL,
+    owner_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_two_factor_recovery_owner (owner_kind, owner_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- A password that checked out, waiting for its second factor. The session is
+-- only opened once the code is verified.
+CREATE TABLE IF NOT EXISTS login_challenges (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    token_hash CHAR(64) NOT NULL,
+    school_id INT NOT NULL,
+    owner_kind VARCHAR(20) NOT NULL,
+    owner_id INT NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    fullname VARCHAR(255) NOT NULL DEFAULT '',
+    department VARCHAR(255) NOT NULL DEFAULT '',
+    db_slug VARCHAR(255) NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_login_challenges_token (token_hash),
+    CONSTRAINT fk_login_challenges_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,19 @@
+-- Pending invitations for school staff. The account is only created in users
+-- once the invitee accepts and picks a password. Only a SHA-256 of the token
+-- is stored.
+CREATE TABLE IF NOT EXISTS staff_invitations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    fullname VARCHAR(255) NOT NULL DEFAULT '',
+    role VARCHAR(50) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    invited_by INT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_staff_invitations_token (token_hash),
+    KEY idx_staff_invitations_school (school_id, email),
+    CONSTRAINT fk_staff_invitations_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
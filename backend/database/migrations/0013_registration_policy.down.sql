@@ -0,0 +1,9 @@
+DROP INDEX idx_teachers_school_employee ON teachers;
+DROP INDEX idx_students_school_regno ON students;
+
+ALTER TABLE teachers DROP COLUMN status;
+ALTER TABLE students DROP COLUMN status;
+
+ALTER TABLE schools
+    DROP COLUMN registration_code,
+    DROP COLUMN registration_mode;
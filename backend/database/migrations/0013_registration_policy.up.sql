@@ -0,0 +1,17 @@
+-- How students and teachers may join a school: closed (only staff create
+-- accounts), invite-code (self sign-up with the school's code) or
+-- open-with-approval (anyone may sign up, an admin approves). Existing schools
+-- keep self sign-up but new accounts now wait for approval.
+ALTER TABLE schools
+    ADD COLUMN registration_mode VARCHAR(20) NOT NULL DEFAULT 'open-with-approval',
+    ADD COLUMN registration_code VARCHAR(64) NOT NULL DEFAULT '';
+
+-- pending accounts can't sign in until approved
+ALTER TABLE students
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active';
+
+ALTER TABLE teachers
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active';
+
+CREATE INDEX idx_students_school_regno ON students (school_id, registrationNumber);
+CREATE INDEX idx_teachers_school_employee ON teachers (school_id, employeeId);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS enrollment_overrides;
+DROP TABLE IF EXISTS course_requirements;
+DROP TABLE IF EXISTS course_departments;
+
+ALTER TABLE courses
+    DROP COLUMN max_year,
+    DROP COLUMN min_year;
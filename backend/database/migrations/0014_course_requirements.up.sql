@@ -0,0 +1,42 @@
+-- Year limits for enrolling in a course; 0 means no limit.
+ALTER TABLE courses
+    ADD COLUMN min_year INT NOT NULL DEFAULT 0,
+    ADD COLUMN max_year INT NOT NULL DEFAULT 0;
+
+-- Departments whose students may take a course. A course with no rows here
+-- is open to every department.
+CREATE TABLE IF NOT EXISTS course_departments (
+    course_id INT NOT NULL,
+    department_id INT NOT NULL,
+    PRIMARY KEY (course_id, department_id),
+    CONSTRAINT fk_course_departments_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+    CONSTRAINT fk_course_departments_department FOREIGN KEY (department_id) REFERENCES departments (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- kind is prerequisite (taken before) or corequisite (taken before or
+-- alongside).
+CREATE TABLE IF NOT EXISTS course_requirements (
+    course_id INT NOT NULL,
+    required_course_id INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    PRIMARY KEY (course_id, required_course_id),
+    KEY idx_course_requirements_required (required_course_id),
+    CONSTRAINT fk_course_requirements_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+    CONSTRAINT fk_course_requirements_required FOREIGN KEY (required_course_id) REFERENCES courses (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Enrollments an admin pushed through despite unmet requirements, with what
+-- was unmet at the time.
+CREATE TABLE IF NOT EXISTS enrollment_overrides (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    student_id INT NOT NULL,
+    course_id INT NOT NULL,
+    overridden_by INT NOT NULL,
+    overridden_by_role VARCHAR(50) NOT NULL,
+    reason VARCHAR(500) NOT NULL,
+    unmet TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_enrollment_overrides_student (student_id),
+    CONSTRAINT fk_enrollment_overrides_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE,
+    CONSTRAINT fk_enrollment_overrides_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
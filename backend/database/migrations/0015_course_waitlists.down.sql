@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS course_waitlist;
+
+ALTER TABLE courses
+    DROP COLUMN capacity;
@@ -0,0 +1,16 @@
+-- Seats in a course; 0 means unlimited.
+ALTER TABLE courses
+    ADD COLUMN capacity INT NOT NULL DEFAULT 0;
+
+-- Students queued for a full course. The queue is ordered by id, so the
+-- earliest request is promoted first.
+CREATE TABLE IF NOT EXISTS course_waitlist (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    course_id INT NOT NULL,
+    student_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_course_waitlist_student (course_id, student_id),
+    KEY idx_course_waitlist_student (student_id),
+    CONSTRAINT fk_course_waitlist_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+    CONSTRAINT fk_course_waitlist_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS enrollment_requests;
+DROP TABLE IF EXISTS enrollment_history;
+
+ALTER TABLE semesters
+    DROP COLUMN add_drop_closes_on,
+    DROP COLUMN add_drop_opens_on;
@@ -0,0 +1,41 @@
+-- The add/drop period of a semester. Outside every window students need an
+-- admin to approve course changes; schools that set none are always open.
+ALTER TABLE semesters
+    ADD COLUMN add_drop_opens_on DATE NULL,
+    ADD COLUMN add_drop_closes_on DATE NULL;
+
+-- Every change to a student's courses. action is add, waitlist or drop;
+-- actor_role is "waitlist" for automatic promotions.
+CREATE TABLE IF NOT EXISTS enrollment_history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    student_id INT NOT NULL,
+    course_id INT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    actor_role VARCHAR(50) NOT NULL,
+    actor_id INT NOT NULL DEFAULT 0,
+    note VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_enrollment_history_student (student_id),
+    KEY idx_enrollment_history_course (course_id),
+    CONSTRAINT fk_enrollment_history_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE,
+    CONSTRAINT fk_enrollment_history_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Adds and drops students asked for outside the add/drop period.
+CREATE TABLE IF NOT EXISTS enrollment_requests (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    student_id INT NOT NULL,
+    course_id INT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    reason VARCHAR(500) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    decided_by INT NULL,
+    decided_by_role VARCHAR(50) NULL,
+    decision_note VARCHAR(500) NOT NULL DEFAULT '',
+    decided_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_enrollment_requests_student (student_id),
+    KEY idx_enrollment_requests_status (status),
+    CONSTRAINT fk_enrollment_requests_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE,
+    CONSTRAINT fk_enrollment_requests_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
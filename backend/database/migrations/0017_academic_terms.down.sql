@@ -0,0 +1,30 @@
+ALTER TABLE attendance_sessions DROP COLUMN term_id;
+
+ALTER TABLE cats
+    DROP INDEX idx_cats_term,
+    DROP COLUMN term_id;
+
+ALTER TABLE class_schedules
+    DROP INDEX idx_class_schedules_term,
+    DROP COLUMN term_id;
+
+ALTER TABLE enrollment_requests DROP COLUMN term_id;
+ALTER TABLE enrollment_history DROP COLUMN term_id;
+
+-- Fails if a student has taken the same course in more than one term.
+ALTER TABLE course_waitlist
+    ADD UNIQUE KEY uq_course_waitlist_student (course_id, student_id),
+    DROP INDEX uq_course_waitlist_term,
+    DROP COLUMN term_id;
+
+ALTER TABLE student_courses
+    ADD UNIQUE KEY uq_student_courses (student_id, course_id),
+    DROP INDEX uq_student_courses_term,
+    DROP COLUMN term_id;
+
+ALTER TABLE semesters
+    DROP FOREIGN KEY fk_semesters_academic_year,
+    DROP COLUMN is_current,
+    DROP COLUMN academic_year_id;
+
+DROP TABLE IF EXISTS academic_years;
@@ -0,0 +1,117 @@
+-- Academic years group a school's terms. The terms themselves are the rows
+-- of semesters. At most one year and one term per school is current.
+CREATE TABLE IF NOT EXISTS academic_years (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL,
+    is_current TINYINT(1) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_academic_years_school_name (school_id, name),
+    CONSTRAINT fk_academic_years_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE semesters
+    ADD COLUMN academic_year_id INT NULL,
+    ADD COLUMN is_current TINYINT(1) NOT NULL DEFAULT 0,
+    ADD CONSTRAINT fk_semesters_academic_year FOREIGN KEY (academic_year_id) REFERENCES academic_years (id) ON DELETE SET NULL;
+
+-- term_id refers to semesters.id. It is 0 rather than NULL for rows that
+-- belong to no term, so a student can take a course once per term and the
+-- unique keys still hold for rows without one.
+ALTER TABLE student_courses
+    ADD COLUMN term_id INT NOT NULL DEFAULT 0,
+    ADD UNIQUE KEY uq_student_courses_term (student_id, course_id, term_id),
+    DROP INDEX uq_student_courses;
+
+ALTER TABLE course_waitlist
+    ADD COLUMN term_id INT NOT NULL DEFAULT 0,
+    ADD UNIQUE KEY uq_course_waitlist_term (course_id, student_id, term_id),
+    DROP INDEX uq_course_waitlist_student;
+
+ALTER TABLE enrollment_history ADD COLUMN term_id INT NOT NULL DEFAULT 0;
+ALTER TABLE enrollment_requests ADD COLUMN term_id INT NOT NULL DEFAULT 0;
+
+ALTER TABLE class_schedules
+    ADD COLUMN term_id INT NOT NULL DEFAULT 0,
+    ADD KEY idx_class_schedules_term (term_id);
+
+ALTER TABLE cats
+    ADD COLUMN term_id INT NOT NULL DEFAULT 0,
+    ADD KEY idx_cats_term (term_id);
+
+ALTER TABLE attendance_sessions ADD COLUMN term_id INT NOT NULL DEFAULT 0;
+
+-- Place existing rows in a term. Where terms overlap, the one that started
+-- last wins (and then the newer row), as in database.TermOn.
+UPDATE class_schedules cs
+JOIN semesters s ON s.school_id = cs.school_id AND s.name = cs.semester
+SET cs.term_id = s.id;
+
+UPDATE cats
+SET cats.term_id = COALESCE(
+    (SELECT s.id FROM courses c JOIN semesters s ON s.school_id = c.school_id
+     WHERE c.id = cats.course_id AND DATE(cats.cat_datetime) BETWEEN s.starts_on AND s.ends_on
+     ORDER BY s.starts_on DESC, s.id DESC LIMIT 1),
+    0);
+
+UPDATE attendance_sessions a
+JOIN class_schedules cs ON a.schedule_id = cs.id
+SET a.term_id = cs.term_id;
+
+-- Enrollments, waitlist places and their history go in the term their course
+-- is timetabled in, so they join to its schedules and CATs: the first such
+-- term not over when the row was made, else the course's latest term. A
+-- course with no timetable falls back to the term running today.
+UPDATE student_courses sc
+SET sc.term_id = COALESCE(
+    (SELECT cs.term_id FROM class_schedules cs JOIN semesters s ON s.id = cs.term_id
+     WHERE cs.course_id = sc.course_id AND s.ends_on >= DATE(sc.created_at)
+     ORDER BY s.starts_on, s.id LIMIT 1),
+    (SELECT cs.term_id FROM class_schedules cs JOIN semesters s ON s.id = cs.term_id
+     WHERE cs.course_id = sc.course_id
+     ORDER BY s.starts_on DESC, s.id DESC LIMIT 1),
+    (SELECT s.id FROM courses c JOIN semesters s ON s.school_id = c.school_id
+     WHERE c.id = sc.course_id AND CURDATE() BETWEEN s.starts_on AND s.ends_on
+     ORDER BY s.starts_on DESC, s.id DESC LIMIT 1),
+    0);
+
+UPDATE course_waitlist w
+SET w.term_id = COALESCE(
+    (SELECT cs.term_id FROM class_schedules cs JOIN semesters s ON s.id = cs.term_id
+     WHERE cs.course_id = w.course_id AND s.ends_on >= DATE(w.created_at)
+     ORDER BY s.starts_on, s.id LIMIT 1),
+    (SELECT cs.term_id FROM class_schedules cs JOIN semesters s ON s.id = cs.term_id
+     WHERE cs.course_id = w.course_id
+     ORDER BY s.starts_on DESC, s.id DESC LIMIT 1),
+    (SELECT s.id FROM courses c JOIN semesters s ON s.school_id = c.school_id
+     WHERE c.id = w.course_id AND CURDATE() BETWEEN s.starts_on AND s.ends_on
+     ORDER BY s.starts_on DESC, s.id DESC LIMIT 1),
+    0);
+
+UPDATE enrollment_history h
+SET h.term_id = COALESCE(
+    (SELECT cs.term_id FROM class_schedules cs JOIN semesters s ON s.id = cs.term_id
+     WHERE cs.course_id = h.course_id AND s.ends_on >= DATE(h.created_at)
+     ORDER BY s.starts_on, s.id LIMIT 1),
+    (SELECT cs.term_id FROM class_schedules cs JOIN semesters s ON s.id = cs.term_id
+     WHERE cs.course_id = h.course_id
+     ORDER BY s.starts_on DESC, s.id DESC LIMIT 1),
+    (SELECT s.id FROM courses c JOIN semesters s ON s.school_id = c.school_id
+     WHERE c.id = h.course_id AND CURDATE() BETWEEN s.starts_on AND s.ends_on
+     ORDER BY s.starts_on DESC, s.id DESC LIMIT 1),
+    0);
+
+UPDATE enrollment_requests r
+SET r.term_id = COALESCE(
+    (SELECT cs.term_id FROM class_schedules cs JOIN semesters s ON s.id = cs.term_id
+     WHERE cs.course_id = r.course_id AND s.ends_on >= DATE(r.created_at)
+     ORDER BY s.starts_on, s.id LIMIT 1),
+    (SELECT cs.term_id FROM class_schedules cs JOIN semesters s ON s.id = cs.term_id
+     WHERE cs.course_id = r.course_id
+     ORDER BY s.starts_on DESC, s.id DESC LIMIT 1),
+    (SELECT s.id FROM courses c JOIN semesters s ON s.school_id = c.school_id
+     WHERE c.id = r.course_id AND CURDATE() BETWEEN s.starts_on AND s.ends_on
+     ORDER BY s.starts_on DESC, s.id DESC LIMIT 1),
+    0);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS promotion_records;
+
+ALTER TABLE schools
+    DROP COLUMN promotion_final_year,
+    DROP COLUMN promotion_pass_mark,
+    DROP COLUMN promotion_min_credits;
@@ -0,0 +1,30 @@
+-- Each school's end-of-year rules: a student needs min_credits worth of
+-- passed courses in the year to move up, where a course is passed with at
+-- least pass_mark percent of its published CAT marks. Students in final_year
+-- who meet the rule graduate.
+ALTER TABLE schools
+    ADD COLUMN promotion_min_credits INT NOT NULL DEFAULT 0,
+    ADD COLUMN promotion_pass_mark DECIMAL(5,2) NOT NULL DEFAULT 50,
+    ADD COLUMN promotion_final_year INT NOT NULL DEFAULT 4;
+
+-- One outcome per student per academic year: promoted, repeated or
+-- graduated. Repeaters are the students with a repeated row for the year.
+CREATE TABLE IF NOT EXISTS promotion_records (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    school_id INT NOT NULL,
+    academic_year_id INT NOT NULL,
+    student_id INT NOT NULL,
+    from_year VARCHAR(20) NOT NULL,
+    to_year VARCHAR(20) NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    credits_passed INT NOT NULL,
+    credits_required INT NOT NULL,
+    processed_by_role VARCHAR(20) NOT NULL,
+    processed_by INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_promotion_records_student_year (student_id, academic_year_id),
+    KEY idx_promotion_records_year (academic_year_id, outcome),
+    CONSTRAINT fk_promotion_records_school FOREIGN KEY (school_id) REFERENCES schools (id) ON DELETE CASCADE,
+    CONSTRAINT fk_promotion_records_year FOREIGN KEY (academic_year_id) REFERENCES academic_years (id) ON DELETE CASCADE,
+    CONSTRAINT fk_promotion_records_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE login_throttles DROP INDEX idx_login_throttles_last_failure;
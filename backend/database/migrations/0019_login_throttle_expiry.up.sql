@@ -0,0 +1,2 @@
+-- Expired failure counters are purged by age as new failures are recorded.
+ALTER TABLE login_throttles ADD KEY idx_login_throttles_last_failure (last_failure_at);
@@ -0,0 +1,3 @@
+-- The hashes can't be turned back into tokens; owners create new feeds.
+DELETE FROM calendar_tokens;
+ALTER TABLE calendar_tokens CHANGE COLUMN token_hash token CHAR(64) NOT NULL;
@@ -0,0 +1,5 @@
+-- Calendar feed tokens are stored as a SHA-256, like reset tokens, so a
+-- leaked table doesn't hand out every timetable. Hashing in place keeps
+-- existing subscription URLs working.
+ALTER TABLE calendar_tokens CHANGE COLUMN token token_hash CHAR(64) NOT NULL;
+UPDATE calendar_tokens SET token_hash = SHA2(token_hash, 256);
@@ -0,0 +1,7 @@
+ALTER TABLE enrollment_overrides DROP FOREIGN KEY fk_enrollment_overrides_course;
+ALTER TABLE enrollment_overrides
+    ADD CONSTRAINT fk_enrollment_overrides_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE;
+
+ALTER TABLE enrollment_history DROP FOREIGN KEY fk_enrollment_history_course;
+ALTER TABLE enrollment_history
+    ADD CONSTRAINT fk_enrollment_history_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE;
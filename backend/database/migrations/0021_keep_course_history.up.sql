@@ -0,0 +1,10 @@
+-- Enrollment history and requirement overrides are records of what happened;
+-- deleting a course must not quietly take them with it. DeleteCourse refuses
+-- courses that have any, so they are deactivated instead.
+ALTER TABLE enrollment_history DROP FOREIGN KEY fk_enrollment_history_course;
+ALTER TABLE enrollment_history
+    ADD CONSTRAINT fk_enrollment_history_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE RESTRICT;
+
+ALTER TABLE enrollment_overrides DROP FOREIGN KEY fk_enrollment_overrides_course;
+ALTER TABLE enrollment_overrides
+    ADD CONSTRAINT fk_enrollment_overrides_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE RESTRICT;
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound means the row doesn't exist or belongs to another school.
+// Callers must not tell the two apart.
+var ErrNotFound = errors.New("not found in school")
+
+// Tenant scopes lookups to a single school.
+type Tenant int
+
+// scopedQueries check that a row of each kind belongs to the school.
+// Every query takes the row id followed by the school id.
+var scopedQueries = map[string]string{
+	"student":  "SELECT EXISTS (SELECT 1 FROM students WHERE id = ? AND school_id = ?)",
+	"teacher":  "SELECT EXISTS (SELECT 1 FROM teachers WHERE id = ? AND school_id = ?)",
+	"course":   "SELECT EXISTS (SELECT 1 FROM courses WHERE id = ? AND school_id = ?)",
+	"schedule": "SELECT EXISTS (SELECT 1 FROM class_schedules WHERE id = ? AND school_id = ?)",
+	"cat": `SELECT EXISTS (
+		SELECT 1 FROM cats JOIN courses ON cats.course_id = courses.id
+		WHERE cats.id = ? AND courses.school_id = ?)`,
+	"attendance": "SELECT EXISTS (SELECT 1 FROM attendance_sessions WHERE id = ? AND school_id = ?)",
+	"term":       "SELECT EXISTS (SELECT 1 FROM semesters WHERE id = ? AND school_id = ?)",
+	"year":       "SELECT EXISTS (SELECT 1 FROM academic_years WHERE id = ? AND school_id = ?)",
+}
+
+func (t Tenant) owns(kind string, id interface{}) error {
+	var exists bool
+	if err := DB.QueryRow(scopedQueries[kind], id, int(t)).Scan(&exists); err != nil {
+		return fmt.Errorf("check %s %v: %w", kind, id, err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (t Tenant) Student(id interface{}) error  { return t.owns("student", id) }
+func (t Tenant) Teacher(id interface{}) error  { return t.owns("teacher", id) }
+func (t Tenant) Course(id interface{}) error   { return t.owns("course", id) }
+func (t Tenant) Cat(id interface{}) error      { return t.owns("cat", id) }
+func (t Tenant) Schedule(id interface{}) error { return t.owns("schedule", id) }
+
+func (t Tenant) AttendanceSession(id interface{}) error { return t.owns("attendance", id) }
+
+func (t Tenant) Term(id interface{}) error         { return t.owns("term", id) }
+func (t Tenant) AcademicYear(id interface{}) error { return t.owns("year", id) }
@@ -0,0 +1,37 @@
+package database
+
+import "database/sql"
+
+// RowQuerier is a *sql.DB or *sql.Tx.
+type RowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// CurrentTermID returns the school's current term, or 0 when no term is
+// marked current.
+func CurrentTermID(q RowQuerier, schoolID int) (int, error) {
+	var id int
+	err := q.QueryRow("SELECT id FROM semesters WHERE school_id = ? AND is_current = 1", schoolID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// TermOn returns the school's term whose dates include day ("2006-01-02"),
+// preferring the current term when terms overlap and falling back to it when
+// none match.
+func TermOn(q RowQuerier, schoolID int, day string) (int, error) {
+	var id int
+	err := q.QueryRow(`
+		SELECT id FROM semesters
+		WHERE school_id = ? AND starts_on <= ? AND ends_on >= ?
+		ORDER BY is_current DESC, starts_on DESC
+		LIMIT 1`,
+		schoolID, day, day,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return CurrentTermID(q, schoolID)
+	}
+	return id, err
+}
@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enrollment_history actions
+const (
+	enrollmentAdd      = "add"
+	enrollmentWaitlist = "waitlist"
+	enrollmentDrop     = "drop"
+)
+
+// enrollment_requests statuses
+const (
+	requestPending  = "pending"
+	requestApproved = "approved"
+	requestRejected = "rejected"
+)
+
+// waitlistActor is recorded as the author of automatic waitlist promotions.
+var waitlistActor = middleware.Principal{Role: "waitlist"}
+
+// recordEnrollment adds a line to the student's enrollment history.
+func recordEnrollment(db execer, studentID, courseID, termID int, action string, by middleware.Principal, note string) error {
+	_, err := db.Exec(`
+		INSERT INTO enrollment_history (student_id, course_id, term_id, action, actor_role, actor_id, note)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		studentID, courseID, termID, action, by.Role, by.ID, note,
+	)
+	return err
+}
+
+type AddDropWindow struct {
+	Semester string `json:"semester"`
+	OpensOn  string `json:"opens_on"`
+	ClosesOn string `json:"closes_on"`
+}
+
+// addDropStatus reports whether students may change their own courses in a
+// term today, along with that term's window. A school that has set no
+// windows at all is always open; otherwise a term without a window is closed.
+func addDropStatus(q querier, schoolID, termID int) (bool, *AddDropWindow, error) {
+	var w AddDropWindow
+	var opensOn, closesOn time.Time
+	var open bool
+	err := q.QueryRow(`
+		SELECT name, add_drop_opens_on, add_drop_closes_on, CURDATE() BETWEEN add_drop_opens_on AND add_drop_closes_on
+		FROM semesters
+		WHERE id = ? AND school_id = ? AND add_drop_opens_on IS NOT NULL AND add_drop_closes_on IS NOT NULL`,
+		termID, schoolID,
+	).Scan(&w.Semester, &opensOn, &closesOn, &open)
+	if err == nil {
+		w.OpensOn = opensOn.Format("2006-01-02")
+		w.ClosesOn = closesOn.Format("2006-01-02")
+		return open, &w, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, nil, err
+	}
+
+	var configured bool
+	err = q.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM semesters WHERE school_id = ? AND add_drop_closes_on IS NOT NULL)", schoolID,
+	).Scan(&configured)
+	return !configured, nil, err
+}
+
+// requestEnrollmentChange queues an add or drop in a term for an admin to
+// approve. A matching request that is still pending is reused. It returns the
+// request id.
+func requestEnrollmentChange(tx *sql.Tx, studentID, courseID, termID int, action, reason string) (int, error) {
+	var id int
+	err := tx.QueryRow(`
+		SELECT id FROM enrollment_requests
+		WHERE student_id = ? AND course_id = ? AND term_id = ? AND action = ? AND status = ?`,
+		studentID, courseID, termID, action, requestPending,
+	).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+	res, err := tx.Exec(
+		"INSERT INTO enrollment_requests (student_id, course_id, term_id, action, reason) VALUES (?, ?, ?, ?, ?)",
+		studentID, courseID, termID, action, reason,
+	)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := res.LastInsertId()
+	return int(newID), err
+}
+
+// GetAddDropWindow tells the caller whether students can add and drop
+// courses without approval right now, in the current term or the ?term_id=
+// given.
+func GetAddDropWindow(c *gin.Context) {
+	termID, ok := singleTerm(c)
+	if !ok {
+		return
+	}
+	open, window, err := addDropStatus(database.DB, middleware.CurrentUser(c).SchoolID, termID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check add/drop period"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"open": open, "window": window})
+}
+
+// DropStudentCourse removes a student from a course in the current term, or
+// for staff the ?term_id= given, and hands the seat to the next student on the
+// waitlist. Outside the add/drop period a student's own drop becomes a
+// request for an admin to approve; staff can drop at any time.
+func DropStudentCourse(c *gin.Context) {
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+	courseID, err := strconv.Atoi(c.Param("course_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if len(body.Reason) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason must be at most 500 characters"})
+		return
+	}
+	user := middleware.CurrentUser(c)
+	if user.Role == middleware.RoleStudent && c.Query("term_id") != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Students can only drop courses in the current term"})
+		return
+	}
+	termID, ok := singleTerm(c)
+	if !ok {
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to drop course"})
+		return
+	}
+	defer tx.Rollback()
+
+	if user.Role == middleware.RoleStudent {
+		open, _, err := addDropStatus(tx, user.SchoolID, termID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check add/drop period"})
+			return
+		}
+		if !open {
+			var enrolled bool
+			if err := tx.QueryRow(
+				"SELECT EXISTS (SELECT 1 FROM student_courses WHERE student_id = ? AND course_id = ? AND term_id = ?)",
+				studentID, courseID, termID,
+			).Scan(&enrolled); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Check failed"})
+				return
+			}
+			if !enrolled {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Student is not enrolled in this course"})
+				return
+			}
+			requestID, err := requestEnrollmentChange(tx, studentID, courseID, termID, enrollmentDrop, body.Reason)
+			if err == nil {
+				err = tx.Commit()
+			}
+			if err != nil {
+				log.Printf("[ERROR] Failed to request drop of course %d for student %d: %v", courseID, studentID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request drop"})
+				return
+			}
+			c.JSON(http.StatusAccepted, gin.H{
+				"message":    "The add/drop period is closed, so the drop was sent to an administrator for approval",
+				"request_id": requestID,
+			})
+			return
+		}
+	}
+
+	found, promoted, err := dropCourse(tx, studentID, courseID, termID, user, body.Reason)
+	if err != nil {
+		log.Printf("[ERROR] Failed to drop course %d for student %d: %v", courseID, studentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to drop course"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student is not enrolled in this course"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to drop course"})
+		return
+	}
+
+	log.Printf("[INFO] %s %d dropped student %d from course %d, promoted %v", user.Role, user.ID, studentID, courseID, promoted)
+	c.JSON(http.StatusOK, gin.H{"message": "Course dropped"})
+}
+
+// GetEnrollmentHistory lists every add, waitlist and drop for a student,
+// newest first. ?term_id= narrows it to one term.
+func GetEnrollmentHistory(c *gin.Context) {
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+	scope := middleware.TermScope{All: true}
+	if c.Query("term_id") != "" {
+		if scope, ok = middleware.RequestedTerm(c); !ok {
+			return
+		}
+	}
+	inTerm, termArgs := scope.Where("h.term_id")
+
+	rows, err := database.DB.Query(`
+		SELECT h.id, h.course_id, c.code, c.name, h.term_id, h.action, h.actor_role, h.actor_id, h.note, h.created_at
+		FROM enrollment_history h
+		JOIN courses c ON h.course_id = c.id
+		WHERE h.student_id = ? AND `+inTerm+`
+		ORDER BY h.id DESC`,
+		append([]interface{}{studentID}, termArgs...)...,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollment history"})
+		return
+	}
+	defer rows.Close()
+
+	type Entry struct {
+		ID         int       `json:"id"`
+		CourseID   int       `json:"course_id"`
+		CourseCode string    `json:"course_code"`
+		CourseName string    `json:"course_name"`
+		TermID     int       `json:"term_id"`
+		Action     string    `json:"action"`
+		ActorRole  string    `json:"actor_role"`
+		ActorID    int       `json:"actor_id"`
+		Note       string    `json:"note"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+	history := []Entry{}
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.CourseID, &e.CourseCode, &e.CourseName, &e.TermID, &e.Action, &e.ActorRole, &e.ActorID,
+			&e.Note, &e.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read enrollment history"})
+			return
+		}
+		history = append(history, e)
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+type EnrollmentRequest struct {
+	ID           int        `json:"id"`
+	StudentID    int        `json:"student_id"`
+	StudentName  string     `json:"student_name"`
+	CourseID     int        `json:"course_id"`
+	CourseCode   string     `json:"course_code"`
+	TermID       int        `json:"term_id"`
+	Action       string     `json:"action"`
+	Reason       string     `json:"reason"`
+	Status       string     `json:"status"`
+	DecisionNote string     `json:"decision_note"`
+	DecidedAt    *time.Time `json:"decided_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// listEnrollmentRequests runs a request query whose remaining conditions and
+// arguments are given by the caller.
+func listEnrollmentRequests(c *gin.Context, where string, args ...interface{}) {
+	rows, err := database.DB.Query(`
+		SELECT r.id, r.student_id, s.fullname, r.course_id, c.code, r.term_id, r.action, r.reason, r.status,
+		       r.decision_note, r.decided_at, r.created_at
+		FROM enrollment_requests r
+		JOIN students s ON r.student_id = s.id
+		JOIN courses c ON r.course_id = c.id
+		WHERE `+where+`
+		ORDER BY r.created_at ASC`,
+		args...,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollment requests"})
+		return
+	}
+	defer rows.Close()
+
+	requests := []EnrollmentRequest{}
+	for rows.Next() {
+		var r EnrollmentRequest
+		var decidedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.StudentID, &r.StudentName, &r.CourseID, &r.CourseCode, &r.TermID, &r.Action, &r.Reason,
+			&r.Status, &r.DecisionNote, &decidedAt, &r.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read enrollment requests"})
+			return
+		}
+		if decidedAt.Valid {
+			r.DecidedAt = &decidedAt.Time
+		}
+		requests = append(requests, r)
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// GetStudentEnrollmentRequests lists a student's add/drop requests.
+func GetStudentEnrollmentRequests(c *gin.Context) {
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+	listEnrollmentRequests(c, "r.student_id = ?", studentID)
+}
+
+// ListEnrollmentRequests is the approval queue for late adds and drops,
+// oldest first. ?status= picks approved or rejected ones, or all.
+func ListEnrollmentRequests(c *gin.Context) {
+	schoolID := middleware.CurrentUser(c).SchoolID
+	switch status := c.DefaultQuery("status", requestPending); status {
+	case "all":
+		listEnrollmentRequests(c, "s.school_id = ?", schoolID)
+	case requestPending, requestApproved, requestRejected:
+		listEnrollmentRequests(c, "s.school_id = ? AND r.status = ?", schoolID, status)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, approved, rejected or all"})
+	}
+}
+
+// ApproveEnrollmentRequest carries out a late add or drop. An approved add
+// still respects the course's capacity, so it may land on the waitlist.
+func ApproveEnrollmentRequest(c *gin.Context) {
+	decideEnrollmentRequest(c, requestApproved)
+}
+
+func RejectEnrollmentRequest(c *gin.Context) {
+	decideEnrollmentRequest(c, requestRejected)
+}
+
+func decideEnrollmentRequest(c *gin.Context, decision string) {
+	var body struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+	body.Note = strings.TrimSpace(body.Note)
+	if len(body.Note) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Note must be at most 500 characters"})
+		return
+	}
+	user := middleware.CurrentUser(c)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update request"})
+		return
+	}
+	defer tx.Rollback()
+
+	var requestID, studentID, courseID, termID int
+	var action, status string
+	err = tx.QueryRow(`
+		SELECT r.id, r.student_id, r.course_id, r.term_id, r.action, r.status
+		FROM enrollment_requests r
+		JOIN students s ON r.student_id = s.id
+		WHERE r.id = ? AND s.school_id = ?
+		FOR UPDATE`,
+		c.Param("id"), user.SchoolID,
+	).Scan(&requestID, &studentID, &courseID, &termID, &action, &status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Request not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch request"})
+		return
+	}
+	if status != requestPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request has already been " + status})
+		return
+	}
+
+	fail := func(err error) {
+		log.Printf("[ERROR] Failed to %s enrollment request %d: %v", strings.TrimSuffix(decision, "d"), requestID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update request"})
+	}
+	result := gin.H{"message": "Request " + decision}
+	if decision == requestApproved {
+		note := "Approved request #" + strconv.Itoa(requestID)
+		if action == enrollmentDrop {
+			if _, _, err := dropCourse(tx, studentID, courseID, termID, user, note); err != nil {
+				fail(err)
+				return
+			}
+		} else {
+			var enrolled bool
+			if err := tx.QueryRow(
+				"SELECT EXISTS (SELECT 1 FROM student_courses WHERE student_id = ? AND course_id = ? AND term_id = ?)",
+				studentID, courseID, termID,
+			).Scan(&enrolled); err != nil {
+				fail(err)
+				return
+			}
+			if !enrolled {
+				position, err := enrollOrWaitlist(tx, studentID, courseID, termID, user, note)
+				if err != nil {
+					fail(err)
+					return
+				}
+				result["waitlist_position"] = position
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE enrollment_requests
+		SET status = ?, decided_by = ?, decided_by_role = ?, decision_note = ?, decided_at = NOW()
+		WHERE id = ?`,
+		decision, user.ID, user.Role, body.Note, requestID,
+	); err != nil {
+		fail(err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		fail(err)
+		return
+	}
+
+	log.Printf("[INFO] %s %d %s %s request %d for student %d course %d", user.Role, user.ID, decision, action, requestID, studentID, courseID)
+	c.JSON(http.StatusOK, result)
+}
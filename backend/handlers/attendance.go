@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AttendancePresent = "present"
+	AttendanceAbsent  = "absent"
+	AttendanceLate    = "late"
+	AttendanceExcused = "excused"
+)
+
+var attendanceStatuses = map[string]bool{
+	AttendancePresent: true, AttendanceAbsent: true, AttendanceLate: true, AttendanceExcused: true,
+}
+
+// AttendanceSummary counts a student's marked sessions. Late counts as
+// attended and excused sessions are left out of the percentage, which is nil
+// until something countable has been recorded.
+type AttendanceSummary struct {
+	Present    int      `json:"present"`
+	Late       int      `json:"late"`
+	Absent     int      `json:"absent"`
+	Excused    int      `json:"excused"`
+	Percentage *float64 `json:"percentage"`
+}
+
+func (s *AttendanceSummary) add(status string) {
+	switch status {
+	case AttendancePresent:
+		s.Present++
+	case AttendanceLate:
+		s.Late++
+	case AttendanceAbsent:
+		s.Absent++
+	case AttendanceExcused:
+		s.Excused++
+	}
+	attended := s.Present + s.Late
+	if total := attended + s.Absent; total > 0 {
+		pct := math.Round(float64(attended)*10000/float64(total)) / 100
+		s.Percentage = &pct
+	}
+}
+
+type AttendanceEntry struct {
+	StudentID int    `json:"student_id"`
+	Status    string `json:"status"`
+}
+
+type AttendanceInput struct {
+	Records []AttendanceEntry `json:"records"`
+}
+
+// OpenAttendanceSession starts (or reopens) the register for one meeting of a
+// scheduled class. The date must fall on the slot's weekday.
+func OpenAttendanceSession(c *gin.Context) {
+	var input struct {
+		ScheduleID int    `json:"schedule_id"`
+		Date       string `json:"date"` // "2006-01-02"
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if !middleware.InSchool(c, middleware.Tenant(c).Schedule(input.ScheduleID), "Schedule") {
+		return
+	}
+	date, err := time.Parse("2006-01-02", input.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be formatted as YYYY-MM-DD"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	var teacherID, courseID, termID int
+	var day string
+	var startsOn, endsOn sql.NullTime
+	err = database.DB.QueryRow(`
+		SELECT cs.teacher_id, cs.course_id, cs.term_id, cs.day_of_week, sem.starts_on, sem.ends_on
+		FROM class_schedules cs
+		LEFT JOIN semesters sem ON sem.id = cs.term_id
+		WHERE cs.id = ?`,
+		input.ScheduleID,
+	).Scan(&teacherID, &courseID, &termID, &day, &startsOn, &endsOn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
+		return
+	}
+	if user.Role == middleware.RoleTeacher && teacherID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+	if date.Weekday().String() != day {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This class meets on " + day + ", not " + date.Weekday().String()})
+		return
+	}
+	if startsOn.Valid && endsOn.Valid && (date.Before(startsOn.Time) || date.After(endsOn.Time)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date is outside the semester"})
+		return
+	}
+
+	status := http.StatusCreated
+	res, err := database.DB.Exec(`
+		INSERT INTO attendance_sessions (school_id, schedule_id, course_id, teacher_id, session_date, opened_by, term_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.SchoolID, input.ScheduleID, courseID, teacherID, input.Date, user.ID, termID,
+	)
+	var sessionID int64
+	if database.IsDuplicate(err) {
+		status = http.StatusOK
+		err = database.DB.QueryRow(
+			"SELECT id FROM attendance_sessions WHERE schedule_id = ? AND session_date = ?", input.ScheduleID, input.Date,
+		).Scan(&sessionID)
+	} else if err == nil {
+		sessionID, err = res.LastInsertId()
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to open attendance for schedule %d on %s: %v", input.ScheduleID, input.Date, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open attendance session"})
+		return
+	}
+
+	writeAttendanceRoster(c, status, sessionID)
+}
+
+// GetAttendanceSession returns the register: every enrolled student with the
+// status recorded so far, or null if not yet marked.
+func GetAttendanceSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	if !ownsAttendanceSession(c, sessionID) {
+		return
+	}
+	id, _ := strconv.ParseInt(sessionID, 10, 64)
+	writeAttendanceRoster(c, http.StatusOK, id)
+}
+
+func writeAttendanceRoster(c *gin.Context, status int, sessionID int64) {
+	var courseID, termID int
+	var scheduleID sql.NullInt64
+	var date time.Time
+	err := database.DB.QueryRow(
+		"SELECT schedule_id, course_id, term_id, session_date FROM attendance_sessions WHERE id = ?", sessionID,
+	).Scan(&scheduleID, &courseID, &termID, &date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance session"})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.fullname, s.registrationNumber, ar.status, ar.checked_in_at
+		FROM student_courses sc
+		JOIN students s ON sc.student_id = s.id
+		LEFT JOIN attendance_records ar ON ar.student_id = s.id AND ar.session_id = ?
+		WHERE sc.course_id = ? AND sc.term_id = ?
+		ORDER BY s.fullname ASC`,
+		sessionID, courseID, termID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch register for session %d: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance"})
+		return
+	}
+	defer rows.Close()
+
+	type RosterEntry struct {
+		StudentID          int        `json:"student_id"`
+		FullName           string     `json:"fullname"`
+		RegistrationNumber string     `json:"registrationNumber"`
+		Status             *string    `json:"status"`
+		CheckedInAt        *time.Time `json:"checked_in_at,omitempty"`
+	}
+
+	roster := []RosterEntry{}
+	for rows.Next() {
+		var r RosterEntry
+		var st sql.NullString
+		var checkedIn sql.NullTime
+		if err := rows.Scan(&r.StudentID, &r.FullName, &r.RegistrationNumber, &st, &checkedIn); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read attendance"})
+			return
+		}
+		if st.Valid {
+			r.Status = &st.String
+		}
+		if checkedIn.Valid {
+			r.CheckedInAt = &checkedIn.Time
+		}
+		roster = append(roster, r)
+	}
+
+	var schedule interface{}
+	if scheduleID.Valid {
+		schedule = scheduleID.Int64
+	}
+	c.JSON(status, gin.H{
+		"session_id":  sessionID,
+		"schedule_id": schedule,
+		"course_id":   courseID,
+		"term_id":     termID,
+		"date":        date.Format("2006-01-02"),
+		"students":    roster,
+	})
+}
+
+// SaveAttendance marks students in bulk. Every student must be enrolled in the
+// session's course; the whole batch is rejected otherwise.
+func SaveAttendance(c *gin.Context) {
+	sessionID := c.Param("id")
+	var input AttendanceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if !ownsAttendanceSession(c, sessionID) {
+		return
+	}
+
+	var courseID, termID int
+	if err := database.DB.QueryRow(
+		"SELECT course_id, term_id FROM attendance_sessions WHERE id = ?", sessionID,
+	).Scan(&courseID, &termID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance session"})
+		return
+	}
+
+	enrolled := make(map[int]bool)
+	rows, err := database.DB.Query("SELECT student_id FROM student_courses WHERE course_id = ? AND term_id = ?", courseID, termID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollments"})
+		return
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			enrolled[id] = true
+		}
+	}
+	rows.Close()
+
+	var invalid []gin.H
+	for _, r := range input.Records {
+		switch {
+		case !enrolled[r.StudentID]:
+			invalid = append(invalid, gin.H{"student_id": r.StudentID, "error": "Student is not enrolled in this course"})
+		case !attendanceStatuses[r.Status]:
+			invalid = append(invalid, gin.H{"student_id": r.StudentID, "error": "Status must be present, absent, late or excused"})
+		}
+	}
+	if len(invalid) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Some records were rejected", "invalid": invalid})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attendance"})
+		return
+	}
+	defer tx.Rollback()
+
+	recordedBy := middleware.CurrentUser(c).ID
+	for _, r := range input.Records {
+		_, err := tx.Exec(`
+			INSERT INTO attendance_records (session_id, student_id, status, recorded_by)
+			VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE status = VALUES(status), recorded_by = VALUES(recorded_by)`,
+			sessionID, r.StudentID, r.Status, recordedBy,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to save attendance for student %d in session %s: %v", r.StudentID, sessionID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attendance"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attendance"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Attendance saved", "saved": len(input.Records)})
+}
+
+// ownsAttendanceSession checks the session is in the caller's school and, for
+// teachers, that it is one of their classes. It writes the error response.
+func ownsAttendanceSession(c *gin.Context, sessionID string) bool {
+	if !middleware.InSchool(c, middleware.Tenant(c).AttendanceSession(sessionID), "Attendance session") {
+		return false
+	}
+
+	user := middleware.CurrentUser(c)
+	if user.Role != middleware.RoleTeacher {
+		return true
+	}
+
+	var teacherID int
+	err := database.DB.QueryRow("SELECT teacher_id FROM attendance_sessions WHERE id = ?", sessionID).Scan(&teacherID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance session"})
+		return false
+	}
+	if teacherID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return false
+	}
+	return true
+}
+
+// GetCourseAttendance reports attendance percentages for every student
+// enrolled in a course, plus the course as a whole.
+func GetCourseAttendance(c *gin.Context) {
+	courseID := c.Param("id")
+	if !middleware.InSchool(c, middleware.Tenant(c).Course(courseID), "Course") {
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	if user.Role == middleware.RoleTeacher {
+		var teaches bool
+		err := database.DB.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM teacher_courses WHERE teacher_id = ? AND course_id = ?)", user.ID, courseID,
+		).Scan(&teaches)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+			return
+		}
+		if !teaches {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+	}
+	scope, ok := middleware.RequestedTerm(c)
+	if !ok {
+		return
+	}
+	inTerm, termArgs := scope.Where("sc.term_id")
+	sessionsInTerm, sessionArgs := scope.Where("term_id")
+
+	var sessions int
+	if err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM attendance_sessions WHERE course_id = ? AND "+sessionsInTerm,
+		append([]interface{}{courseID}, sessionArgs...)...,
+	).Scan(&sessions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance"})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.fullname, s.registrationNumber, ar.status
+		FROM student_courses sc
+		JOIN students s ON sc.student_id = s.id
+		LEFT JOIN attendance_sessions ses ON ses.course_id = sc.course_id AND ses.term_id = sc.term_id
+		LEFT JOIN attendance_records ar ON ar.session_id = ses.id AND ar.student_id = s.id
+		WHERE sc.course_id = ? AND `+inTerm+`
+		ORDER BY s.fullname ASC, s.id ASC`,
+		append([]interface{}{courseID}, termArgs...)...,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch attendance for course %s: %v", courseID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance"})
+		return
+	}
+	defer rows.Close()
+
+	type StudentAttendance struct {
+		StudentID          int    `json:"student_id"`
+		FullName           string `json:"fullname"`
+		RegistrationNumber string `json:"registrationNumber"`
+		AttendanceSummary
+	}
+
+	students := []*StudentAttendance{}
+	byID := make(map[int]*StudentAttendance)
+	var overall AttendanceSummary
+	for rows.Next() {
+		var id int
+		var name, reg string
+		var status sql.NullString
+		if err := rows.Scan(&id, &name, &reg, &status); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read attendance"})
+			return
+		}
+		s, ok := byID[id]
+		if !ok {
+			s = &StudentAttendance{StudentID: id, FullName: name, RegistrationNumber: reg}
+			byID[id] = s
+			students = append(students, s)
+		}
+		if status.Valid {
+			s.add(status.String)
+			overall.add(status.String)
+		}
+	}
+
+	id, _ := strconv.Atoi(courseID)
+	c.JSON(http.StatusOK, gin.H{
+		"course_id": id,
+		"sessions":  sessions,
+		"overall":   overall,
+		"students":  students,
+	})
+}
+
+// GetStudentAttendance lists a student's own attendance history with a
+// summary per course.
+func GetStudentAttendance(c *gin.Context) {
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+	scope, ok := middleware.RequestedTerm(c)
+	if !ok {
+		return
+	}
+	inTerm, termArgs := scope.Where("ses.term_id")
+
+	rows, err := database.DB.Query(`
+		SELECT ses.id, ses.session_date, courses.id, courses.code, courses.name, ar.status
+		FROM attendance_records ar
+		JOIN attendance_sessions ses ON ar.session_id = ses.id
+		JOIN courses ON courses.id = ses.course_id
+		WHERE ar.student_id = ? AND `+inTerm+`
+		ORDER BY ses.session_date DESC, courses.code ASC`,
+		append([]interface{}{studentID}, termArgs...)...,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch attendance for student %d: %v", studentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance"})
+		return
+	}
+	defer rows.Close()
+
+	type HistoryEntry struct {
+		SessionID  int    `json:"session_id"`
+		Date       string `json:"date"`
+		CourseID   int    `json:"course_id"`
+		CourseCode string `json:"course_code"`
+		CourseName string `json:"course_name"`
+		Status     string `json:"status"`
+	}
+	type CourseAttendance struct {
+		CourseID   int    `json:"course_id"`
+		CourseCode string `json:"course_code"`
+		CourseName string `json:"course_name"`
+		AttendanceSummary
+	}
+
+	history := []HistoryEntry{}
+	courses := []*CourseAttendance{}
+	byCourse := make(map[int]*CourseAttendance)
+	for rows.Next() {
+		var h HistoryEntry
+		var date time.Time
+		if err := rows.Scan(&h.SessionID, &date, &h.CourseID, &h.CourseCode, &h.CourseName, &h.Status); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read attendance"})
+			return
+		}
+		h.Date = date.Format("2006-01-02")
+		history = append(history, h)
+
+		ca, ok := byCourse[h.CourseID]
+		if !ok {
+			ca = &CourseAttendance{CourseID: h.CourseID, CourseCode: h.CourseCode, CourseName: h.CourseName}
+			byCourse[h.CourseID] = ca
+			courses = append(courses, ca)
+		}
+		ca.add(h.Status)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"student_id": studentID,
+		"courses":    courses,
+		"history":    history,
+	})
+}
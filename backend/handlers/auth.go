@@ -10,7 +10,9 @@ import (
     "strings" // ✅ Add this
     "time"
     "github.com/gin-gonic/gin"
+    "school-backend/config"
     "school-backend/database"
+    "school-backend/middleware"
     "school-backend/models"
     "school-backend/utils"
 )
@@ -26,21 +28,21 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Log the raw payload
-	log.Printf("[INFO] Registration payload: %s\n", string(bodyBytes))
-
 	// Reset the body so it can be reused
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 	// First bind to student to extract the role
 	var roleExtractor struct {
-		Role string `json:"role"`
-		Slug string `json:"slug"`
+		Role       string `json:"role"`
+		Slug       string `json:"slug"`
+		InviteCode string `json:"invite_code"`
 	}
 	if err := json.Unmarshal(bodyBytes, &roleExtractor); err != nil {
 		log.Printf("[ERROR] Failed to extract role: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid role"})
 		return
 	}
+	// never the raw body: it carries the password and invite code
+	log.Printf("[INFO] Registration attempt: role=%q school=%q", roleExtractor.Role, roleExtractor.Slug)
 
 	switch roleExtractor.Role {
 
@@ -51,25 +53,24 @@ func Register(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid student data"})
 			return
 		}
+		if msg := validateAccount(student.FullName, student.Username, student.Password); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
 
- var schoolID int64
-    err = database.DB.QueryRow("SELECT id FROM schools WHERE slug = ? LIMIT 1", roleExtractor.Slug).Scan(&schoolID)
-    if err != nil {
-        log.Printf("[ERROR] School not found for slug %s: %v", roleExtractor.Slug, err)
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid school slug"})
-        return
-    }
-
-
+		schoolID, status, ok := registrationStatus(c, roleExtractor.Slug, roleExtractor.InviteCode)
+		if !ok {
+			return
+		}
 
 		student.CreatedAt = time.Now()
 		student.Password = utils.HashPassword(student.Password)
 
-		if err := SaveStudentToDB(student, schoolID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save student"})
+		if _, err := createStudent(student, schoolID, status); err != nil {
+			writeCreateError(c, err, "student")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Student registered successfully"})
+		registered(c, "Student", status)
 
 	case "teacher":
 		var teacher models.Teacher
@@ -78,30 +79,43 @@ func Register(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid teacher data"})
 			return
 		}
-		 var schoolID int64
-    err = database.DB.QueryRow("SELECT id FROM schools WHERE slug = ? LIMIT 1", roleExtractor.Slug).Scan(&schoolID)
-    if err != nil {
-        log.Printf("[ERROR] School not found for slug %s: %v", roleExtractor.Slug, err)
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid school slug"})
-        return
-    }
+		if msg := validateAccount(teacher.FullName, teacher.Username, teacher.Password); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		schoolID, status, ok := registrationStatus(c, roleExtractor.Slug, roleExtractor.InviteCode)
+		if !ok {
+			return
+		}
 
 		teacher.CreatedAt = time.Now()
 		teacher.Password = utils.HashPassword(teacher.Password)
 
-		if err := SaveTeacherToDB(teacher, schoolID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save teacher"})
+		if _, err := createTeacher(teacher, schoolID, status); err != nil {
+			writeCreateError(c, err, "teacher")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Teacher registered successfully"})
+		registered(c, "Teacher", status)
 
-	
 	default:
 		log.Printf("[ERROR] Unsupported role: %s", roleExtractor.Role)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported role"})
 	}
 }
 
+// registered tells a new student or teacher whether they can sign in yet.
+func registered(c *gin.Context, who, status string) {
+	if status == accountPending {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": who + " registration received. An administrator must approve it before you can sign in.",
+			"status":  status,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": who + " registered successfully", "status": status})
+}
+
 
 func Login(c *gin.Context) {
 
@@ -123,74 +137,94 @@ func Login(c *gin.Context) {
 
 	switch input.Role {
 	case "student":
+	key := loginKey(input.Role, input.Slug, input.Username)
+	if !allowLogin(c, key) {
+		return
+	}
 
 	var id, schoolID int
-    var dbPassword, fullname, department,  dbSlug string
+    var dbPassword, fullname, department,  dbSlug, status string
     err := database.DB.QueryRow(`
-    SELECT s.id, s.password, s.fullname, s.department, s.school_id, sch.slug
+    SELECT s.id, s.password, s.fullname, s.department, s.school_id, sch.slug, s.status
     FROM students s
     JOIN schools sch ON s.school_id = sch.id
-    WHERE s.username = ?`,
-    input.Username,
-).Scan(&id, &dbPassword, &fullname, &department, &schoolID, &dbSlug)
+    WHERE s.username = ? AND sch.slug = ?`,
+    input.Username, input.Slug,
+).Scan(&id, &dbPassword, &fullname, &department, &schoolID, &dbSlug, &status)
 
 		if err != nil {
 			log.Printf("[ERROR] Student not found: %v\n", err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Student not found"})
+			utils.CheckPassword(input.Password, dummyHash)
+			rejectLogin(c, key, 0)
 			return
 		}
 
-    if dbSlug != input.Slug {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid school"})
-        return
-    }
-
 		if !utils.CheckPassword(input.Password, dbPassword) {
 			log.Printf("[ERROR] Incorrect student password for %s\n", input.Username)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect password"})
+			rejectLogin(c, key, schoolID)
+			return
+		}
+		acceptLogin(key)
+		if !accountUsable(c, status) {
 			return
 		}
 
-		token := utils.GenerateJWT(id, schoolID, fullname, department,dbSlug, "student")
+		err = startSession(c, Account{ID: id, SchoolID: schoolID, Role: "student", FullName: fullname, Department: department, DBSlug: dbSlug})
+		if err != nil {
+			log.Printf("[ERROR] Failed to start session for student %d: %v\n", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+			return
+		}
 
        log.Printf("[INFO] Student login success: %s\n", fullname)
-		c.SetCookie("session_token", token, 3600*24, " /", "localhost", false, true) 
         c.JSON(http.StatusOK, gin.H{"role": "student", "fullname": fullname})
 
 
 	case "teacher":
+		key := loginKey(input.Role, input.Slug, input.Username)
+		if !allowLogin(c, key) {
+			return
+		}
+
 		var id, schoolID int
-        var dbPassword, fullname, department,  dbSlug string
+        var dbPassword, fullname, department,  dbSlug, status string
        err := database.DB.QueryRow(`
-    SELECT t.id, t.password, t.fullname, t.department, t.school_id, sch.slug
+    SELECT t.id, t.password, t.fullname, t.department, t.school_id, sch.slug, t.status
     FROM teachers t
     JOIN schools sch ON t.school_id = sch.id
-    WHERE t.username = ?`,
-    input.Username,
-).Scan(&id, &dbPassword, &fullname, &department, &schoolID, &dbSlug)
+    WHERE t.username = ? AND sch.slug = ?`,
+    input.Username, input.Slug,
+).Scan(&id, &dbPassword, &fullname, &department, &schoolID, &dbSlug, &status)
 
 		if err != nil {
 			log.Printf("[ERROR] Teacher not found: %v\n", err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Teacher not found"})
+			utils.CheckPassword(input.Password, dummyHash)
+			rejectLogin(c, key, 0)
 			return
 		}
 
 
-  if dbSlug != input.Slug {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid school"})
-        return
-    }
-
-
 		if !utils.CheckPassword(input.Password, dbPassword) {
 			log.Printf("[ERROR] Incorrect teacher password for %s\n", input.Username)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect password"})
+			rejectLogin(c, key, schoolID)
+			return
+		}
+		acceptLogin(key)
+		if !accountUsable(c, status) {
+			return
+		}
+
+		account := Account{ID: id, SchoolID: schoolID, Role: "teacher", FullName: fullname, Department: department, DBSlug: dbSlug}
+		if requireSecondFactor(c, account) {
+			return
+		}
+		err = startSession(c, account)
+		if err != nil {
+			log.Printf("[ERROR] Failed to start session for teacher %d: %v\n", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
 			return
 		}
-		
-		token := utils.GenerateJWT(id, schoolID, fullname, department,dbSlug, "teacher")
 
-		c.SetCookie("session_token", token, 3600*24, "/", "localhost", false, true) 
 		log.Printf("[INFO] Teacher login success: %s\n", fullname)
 		c.JSON(http.StatusOK, gin.H{"role": "teacher", "fullname": fullname, "department":department })
 
@@ -200,14 +234,21 @@ func Login(c *gin.Context) {
 	}
 }
 
-func SaveStudentToDB(student models.Student, schoolID int64) error {
+// setSessionCookie stores the JWT in an HttpOnly cookie that expires with it.
+func setSessionCookie(c *gin.Context, token string) {
+    c.SetCookie("session_token", token, int(config.App.TokenTTL.Seconds()), "/",
+        config.App.CookieDomain, config.App.CookieSecure, true)
+}
+
+func SaveStudentToDB(db execer, student models.Student, schoolID int64, status string) (int64, error) {
 	query := `INSERT INTO students 
-		(fullname, username, password, registrationNumber, age, year, department , created_at, school_id) 
-		VALUES (?, ?, ?, ?, ?, ?, ?,?,?) `
+		(fullname, username, email, password, registrationNumber, age, year, department , created_at, school_id, status) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?,?,?,?) `
 
-	_, err := database.DB.Exec(query,
+	res, err := db.Exec(query,
 		student.FullName,
 		student.Username,
+		strings.TrimSpace(student.Email),
 		student.Password,
 		student.RegistrationNumber,
 		student.Age,
@@ -215,23 +256,26 @@ func SaveStudentToDB(student models.Student, schoolID int64) error {
 		student.Department,
 		student.CreatedAt,
 		schoolID,
+		status,
 	)
 
 		if err != nil {
 		log.Printf("[ERROR] DB insert failed: %v", err)
+		return 0, err
 	}
 	
-	return err
+	return res.LastInsertId()
 }
 
-func SaveTeacherToDB(teacher models.Teacher,  schoolID int64) error {
+func SaveTeacherToDB(db execer, teacher models.Teacher,  schoolID int64, status string) (int64, error) {
 	query := `INSERT INTO teachers 
-		(fullname, username, password, subject, age, employeeId, department, year, created_at, school_id) 
-		VALUES (?,?,?,?,?,?, ?,?,?,?)`
+		(fullname, username, email, password, subject, age, employeeId, department, year, created_at, school_id, status) 
+		VALUES (?,?,?,?,?,?,?, ?,?,?,?,?)`
 
-	_, err := database.DB.Exec(query,
+	res, err := db.Exec(query,
 		teacher.FullName,
 		teacher.Username,
+		strings.TrimSpace(teacher.Email),
 		teacher.Password,
 		teacher.Subject,
 		teacher.Age,
@@ -240,37 +284,30 @@ func SaveTeacherToDB(teacher models.Teacher,  schoolID int64) error {
         teacher.Year,
         teacher.CreatedAt,
 		schoolID,
+		status,
 
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
 }
 
 
+
 func GetCurrentUser(c *gin.Context) {
     
     log.Println("[DEBUG] Entered GetCurrentUser handler")
 
-    token, err := c.Cookie("session_token")
-    if err != nil {
-        log.Printf("[ERROR] No session cookie found: %v\n", err)
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "No session"})
-        return
-    }
-    log.Printf("[DEBUG] Session token retrieved: %s\n", token)
-
-    id, schoolID,role,fullname, department, dbSlug, err := utils.VerifyJWT(token)
-    if err != nil {
-        log.Printf("[ERROR] JWT verification failed: %v\n", err)
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session"})
-        return
-    }
-    log.Printf("[INFO] Verified JWT -> id=%d, schoolID=%d, role=%s, fullname=%s, department=%s, dbSlug=%s\n",
+    user := middleware.CurrentUser(c)
+    id, schoolID, role, fullname, department, dbSlug := user.ID, user.SchoolID, user.Role, user.FullName, user.Department, user.DBSlug
+    log.Printf("[INFO] Session user -> id=%d, schoolID=%d, role=%s, fullname=%s, department=%s, dbSlug=%s\n",
         id, schoolID, role, fullname, department, dbSlug)
 
     // Fetch branding from schools
     var logoURL, backgroundURL, themeTemplate, logoText, backgroundColor string
     log.Printf("[DEBUG] Fetching branding for schoolID=%d\n", schoolID)
-    err = database.DB.QueryRow(`
+    err := database.DB.QueryRow(`
         SELECT logo_url, background_url, theme_template, logo_text, background_color
         FROM schools
         WHERE id = ?`,
@@ -288,7 +325,7 @@ func GetCurrentUser(c *gin.Context) {
     // Return user info + school branding
     log.Printf("[INFO] Returning user data + branding for userID=%d, role=%s\n", id, role)
 
-    apiBase := "http://localhost:8080"
+    apiBase := config.App.PublicURL
 
     c.JSON(http.StatusOK, gin.H{
         "id":               id,
@@ -306,9 +343,24 @@ func GetCurrentUser(c *gin.Context) {
 }
 
 
+// Logout revokes the current session as well as clearing its cookies, so a
+// copied token stops working too.
 func Logout(c *gin.Context) {
 
-    c.SetCookie("session_token", "", -1, "/", "localhost", false, true)
+    if refresh, err := c.Cookie(refreshCookie); err == nil && refresh != "" {
+        if _, err := database.DB.Exec(
+            "UPDATE sessions SET revoked_at = NOW() WHERE refresh_hash = ? AND revoked_at IS NULL", utils.HashToken(refresh),
+        ); err != nil {
+            log.Printf("[ERROR] Failed to revoke session on logout: %v", err)
+        }
+    }
+    if token, err := c.Cookie("session_token"); err == nil && token != "" {
+        if _, _, sid, _, _, _, _, err := utils.VerifyJWT(token); err == nil {
+            database.DB.Exec("UPDATE sessions SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL", sid)
+        }
+    }
+
+    clearSessionCookies(c)
     c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
@@ -333,11 +385,23 @@ func RegisterSchool(c *gin.Context) {
 
     log.Printf("📥 Received request to register school: Name=%s, Type=%s, Email=%s", req.Name, req.Type, req.Email)
 
-    // Step 1: Insert school with temporary slug
+    // The school, its slug and its admin are created together: a failure
+    // part way must not leave a school without an owner or a placeholder slug
+    // behind.
+    tx, err := database.DB.Begin()
+    if err != nil {
+        log.Printf("❌ Failed to start transaction: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create school"})
+        return
+    }
+    defer tx.Rollback()
+
+    // Step 1: Insert school with a placeholder slug, unique so concurrent
+    // registrations don't collide on uq_schools_slug
     log.Println("💾 Inserting school into DB (with temp slug)...")
-    res, err := database.DB.Exec(
+    res, err := tx.Exec(
         "INSERT INTO schools (name, type, slug) VALUES (?, ?, ?)",
-        req.Name, req.Type, "temp",
+        req.Name, req.Type, "temp-"+utils.RandomToken(16),
     )
     if err != nil {
         log.Printf("❌ Failed to insert school: %v", err)
@@ -354,7 +418,7 @@ func RegisterSchool(c *gin.Context) {
     log.Printf("🔑 Generated slug: %s", slug)
 
     // Step 3: Update slug in DB
-    _, err = database.DB.Exec("UPDATE schools SET slug = ? WHERE id = ?", slug, schoolID)
+    _, err = tx.Exec("UPDATE schools SET slug = ? WHERE id = ?", slug, schoolID)
     if err != nil {
         log.Printf("❌ Failed to update slug: %v", err)
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update slug"})
@@ -367,7 +431,7 @@ func RegisterSchool(c *gin.Context) {
     log.Println("🔒 Password hashed")
 
     // Step 5: Create admin account
-    _, err =database.DB.Exec(
+    _, err = tx.Exec(
     "INSERT INTO users (email, password_hash, role, school_id, phonenumber) VALUES (?, ?, ?, ?, ?)",
     req.Email, hashed, "main-admin", schoolID, req.PhoneNumber,
 )
@@ -376,6 +440,11 @@ func RegisterSchool(c *gin.Context) {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create admin"})
         return
     }
+    if err := tx.Commit(); err != nil {
+        log.Printf("❌ Failed to commit school registration: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create school"})
+        return
+    }
     log.Println("✅ Admin account created")
 
     // Step 6: Respond to frontend
@@ -391,7 +460,19 @@ func RegisterSchool(c *gin.Context) {
 }
 
 //SCHOOL-OWNERSHIP LOGIN
+// Invited staff (registrars, academic admins, auditors) sign in here too.
 func SchoolLogin(c *gin.Context) {
+    staffLogin(c, middleware.StaffRoles...)
+}
+
+// FinanceLogin signs in finance staff, who live in users like school owners.
+func FinanceLogin(c *gin.Context) {
+    staffLogin(c, middleware.RoleFinance)
+}
+
+// staffLogin authenticates a users row by email. Accounts whose role isn't
+// listed get the same answer as a wrong password.
+func staffLogin(c *gin.Context, roles ...string) {
     var req struct {
         Email    string `json:"email"`
         Password string `json:"password"`
@@ -405,31 +486,53 @@ func SchoolLogin(c *gin.Context) {
 
     log.Printf("📥 Login attempt: Email=%s", req.Email)
 
+    key := loginKey(roles[0], "", req.Email)
+    if !allowLogin(c, key) {
+        return
+    }
+
     // Step 1: Find user by email
     var (
         id           int64
         passwordHash string
         role         string
         schoolID     int64
+        fullname     string
     )
 
     err := database.DB.QueryRow(
-        "SELECT id, password_hash, role, school_id FROM users WHERE email = ?",
+        "SELECT id, password_hash, role, school_id, fullname FROM users WHERE email = ?",
         req.Email,
-    ).Scan(&id, &passwordHash, &role, &schoolID)
+    ).Scan(&id, &passwordHash, &role, &schoolID, &fullname)
 
     if err != nil {
         log.Printf("❌ User not found: %v", err)
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+        utils.CheckPassword(req.Password, dummyHash)
+        rejectLogin(c, key, 0)
         return
     }
 
+    allowed := false
+    for _, r := range roles {
+        allowed = allowed || r == role
+    }
+    if !allowed {
+        log.Printf("❌ Role %s cannot use this login", role)
+        utils.CheckPassword(req.Password, dummyHash)
+        rejectLogin(c, key, int(schoolID))
+        return
+    }
+    if fullname == "" {
+        fullname = req.Email // email as username
+    }
+
     // Step 2: Verify password
     if !utils.CheckPassword(req.Password, passwordHash) {
         log.Println("❌ Password mismatch")
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+        rejectLogin(c, key, int(schoolID))
         return
     }
+    acceptLogin(key)
 
     log.Println("🔑 Password verified")
 
@@ -444,18 +547,26 @@ func SchoolLogin(c *gin.Context) {
 
     log.Printf("🏫 User belongs to school (ID=%d, Slug=%s)", schoolID, slug)
 
-    // Step 4: Generate JWT token
-    token := utils.GenerateJWT(
-        int(id),
-        int(schoolID),
-        req.Email,   // email as username
-        "",          // no department
-        slug,        // school slug
-        "main-admin",
-    )
+    account := Account{
+        ID:       int(id),
+        SchoolID: int(schoolID),
+        Role:     role,
+        FullName: fullname,
+        DBSlug:   slug, // no department
+    }
 
-    // Step 5: Set HttpOnly cookie
-    c.SetCookie("session_token", token, 3600*24, "/", "localhost", false, true)
+    // Step 4: Ask for the authenticator code when 2FA is on or required
+    if requireSecondFactor(c, account) {
+        return
+    }
+
+    // Step 5: Open a session and set the HttpOnly cookies
+    err = startSession(c, account)
+    if err != nil {
+        log.Printf("❌ Failed to start session: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+        return
+    }
     log.Println("🍪 Session cookie set")
 
     // Step 6: Respond with success
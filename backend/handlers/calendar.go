@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"school-backend/config"
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultSemesterWeeks bounds weekly classes whose semester has no dates.
+	defaultSemesterWeeks = 16
+	catDuration          = time.Hour
+)
+
+var weekdayIndex = map[string]time.Weekday{
+	"Sunday": time.Sunday, "Monday": time.Monday, "Tuesday": time.Tuesday, "Wednesday": time.Wednesday,
+	"Thursday": time.Thursday, "Friday": time.Friday, "Saturday": time.Saturday,
+}
+
+func calendarURL(token string) string {
+	return config.App.PublicURL + "/ics/" + token + ".ics"
+}
+
+// GetCalendarFeed creates the caller's subscription URL on first use. Only a
+// hash of the token is stored, so an existing URL can't be shown again; a lost
+// one is replaced with RotateCalendarFeed.
+func GetCalendarFeed(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	var createdAt time.Time
+	err := database.DB.QueryRow(
+		"SELECT created_at FROM calendar_tokens WHERE owner_role = ? AND owner_id = ?", user.Role, user.ID,
+	).Scan(&createdAt)
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"created_at": createdAt,
+			"message":    "Your calendar feed is already set up. Rotate it to get a new URL.",
+		})
+		return
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("[ERROR] Failed to load calendar token for %s %d: %v", user.Role, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar feed"})
+		return
+	}
+
+	token := utils.RandomToken(32)
+	_, err = database.DB.Exec(
+		"INSERT INTO calendar_tokens (school_id, owner_role, owner_id, token_hash) VALUES (?, ?, ?, ?)",
+		user.SchoolID, user.Role, user.ID, utils.HashToken(token),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create calendar token for %s %d: %v", user.Role, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"url": calendarURL(token)})
+}
+
+// RotateCalendarFeed invalidates the old subscription URL, e.g. after it leaked
+// or was lost, and returns the new one.
+func RotateCalendarFeed(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	token := utils.RandomToken(32)
+
+	_, err := database.DB.Exec(`
+		INSERT INTO calendar_tokens (school_id, owner_role, owner_id, token_hash) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE token_hash = VALUES(token_hash), created_at = CURRENT_TIMESTAMP`,
+		user.SchoolID, user.Role, user.ID, utils.HashToken(token),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to rotate calendar token for %s %d: %v", user.Role, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": calendarURL(token)})
+}
+
+// ServeCalendarFeed renders a student's or teacher's timetable and CATs as
+// iCalendar. The secret token in the URL is the only credential.
+func ServeCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	var role string
+	var ownerID int
+	err := database.DB.QueryRow(
+		"SELECT owner_role, owner_id FROM calendar_tokens WHERE token_hash = ?", utils.HashToken(token),
+	).Scan(&role, &ownerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	var scheduleQuery, catQuery string
+	switch role {
+	case middleware.RoleStudent:
+		scheduleQuery = `
+		SELECT cs.id, c.code, c.name, cs.day_of_week, cs.start_time, cs.end_time, cs.venue,
+		       cs.semester, cs.created_at, sem.starts_on, sem.ends_on
+		FROM student_courses sc
+		JOIN class_schedules cs ON cs.course_id = sc.course_id AND cs.term_id = sc.term_id
+		JOIN courses c ON c.id = cs.course_id
+		LEFT JOIN semesters sem ON sem.id = cs.term_id
+		WHERE sc.student_id = ?`
+		catQuery = `
+		SELECT cats.id, courses.code, courses.name, cats.cat_datetime
+		FROM cats
+		JOIN courses ON cats.course_id = courses.id
+		JOIN student_courses sc ON sc.course_id = cats.course_id AND sc.term_id = cats.term_id
+		WHERE sc.student_id = ?`
+	case middleware.RoleTeacher:
+		scheduleQuery = `
+		SELECT cs.id, c.code, c.name, cs.day_of_week, cs.start_time, cs.end_time, cs.venue,
+		       cs.semester, cs.created_at, sem.starts_on, sem.ends_on
+		FROM class_schedules cs
+		JOIN courses c ON c.id = cs.course_id
+		LEFT JOIN semesters sem ON sem.id = cs.term_id
+		WHERE cs.teacher_id = ?`
+		catQuery = `
+		SELECT cats.id, courses.code, courses.name, cats.cat_datetime
+		FROM cats
+		JOIN courses ON cats.course_id = courses.id
+		WHERE cats.teacher_id = ?`
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	events, err := scheduleEvents(scheduleQuery, ownerID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to build class events for %s %d: %v", role, ownerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+	cats, err := catEvents(catQuery, ownerID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to build CAT events for %s %d: %v", role, ownerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+	events = append(events, cats...)
+
+	c.Header("Content-Disposition", `inline; filename="timetable.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(utils.BuildICS("Timetable", events)))
+}
+
+// scheduleEvents turns weekly class_schedules rows into recurring events
+// bounded by the semester's dates.
+func scheduleEvents(query string, ownerID int) ([]utils.ICSEvent, error) {
+	rows, err := database.DB.Query(query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []utils.ICSEvent
+	for rows.Next() {
+		var (
+			id                                           int
+			code, name, day, start, end, venue, semester string
+			createdAt                                    time.Time
+			startsOn, endsOn                             sql.NullTime
+		)
+		if err := rows.Scan(&id, &code, &name, &day, &start, &end, &venue, &semester, &createdAt, &startsOn, &endsOn); err != nil {
+			return nil, err
+		}
+
+		weekday, ok := weekdayIndex[day]
+		if !ok {
+			continue
+		}
+		startClock, err1 := time.Parse("15:04:05", start)
+		endClock, err2 := time.Parse("15:04:05", end)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+
+		base := createdAt
+		if startsOn.Valid {
+			base = startsOn.Time
+		}
+		first := time.Date(base.Year(), base.Month(), base.Day(), 0, 0, 0, 0, time.UTC)
+		for first.Weekday() != weekday {
+			first = first.AddDate(0, 0, 1)
+		}
+
+		rrule := fmt.Sprintf("FREQ=WEEKLY;COUNT=%d", defaultSemesterWeeks)
+		if startsOn.Valid && endsOn.Valid {
+			if first.After(endsOn.Time) {
+				continue
+			}
+			until := time.Date(endsOn.Time.Year(), endsOn.Time.Month(), endsOn.Time.Day(), 23, 59, 59, 0, time.UTC)
+			rrule = "FREQ=WEEKLY;UNTIL=" + utils.ICSTime(until, true)
+		}
+
+		clock := func(t time.Time) time.Time {
+			return first.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second)
+		}
+		events = append(events, utils.ICSEvent{
+			UID:         fmt.Sprintf("schedule-%d@school-backend", id),
+			Summary:     code + " " + name,
+			Location:    venue,
+			Description: "Semester: " + semester,
+			Start:       clock(startClock),
+			End:         clock(endClock),
+			Floating:    true,
+			RRule:       rrule,
+		})
+	}
+	return events, rows.Err()
+}
+
+// catEvents turns CATs into single events.
+func catEvents(query string, ownerID int) ([]utils.ICSEvent, error) {
+	rows, err := database.DB.Query(query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []utils.ICSEvent
+	for rows.Next() {
+		var id int
+		var code, name string
+		var at time.Time
+		if err := rows.Scan(&id, &code, &name, &at); err != nil {
+			return nil, err
+		}
+		events = append(events, utils.ICSEvent{
+			UID:     fmt.Sprintf("cat-%d@school-backend", id),
+			Summary: "CAT: " + code + " " + name,
+			Start:   at,
+			End:     at.Add(catDuration),
+		})
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Course and department management for school admins. Every query is scoped
+// to the admin's school; rows from other schools read as not found.
+
+type DepartmentInput struct {
+	Name string `json:"name"`
+}
+
+type CourseInput struct {
+	Name         string `json:"name"`
+	Code         string `json:"code"`
+	DepartmentID *int   `json:"department_id"`
+	CreditUnits  int    `json:"credit_units"`
+	Description  string `json:"description"`
+	// Active defaults to true on create. Left out on update, it stays as it
+	// was.
+	Active *bool `json:"active"`
+	// Capacity is the number of seats, 0 for unlimited. Left out on update,
+	// it stays as it was.
+	Capacity *int `json:"capacity"`
+}
+
+func ListDepartments(c *gin.Context) {
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	rows, err := database.DB.Query(
+		"SELECT id, school_id, name, created_at FROM departments WHERE school_id = ? ORDER BY name ASC",
+		schoolID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list departments for school %d: %v", schoolID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch departments"})
+		return
+	}
+	defer rows.Close()
+
+	departments := []models.Department{}
+	for rows.Next() {
+		var d models.Department
+		if err := rows.Scan(&d.ID, &d.SchoolID, &d.Name, &d.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read department data"})
+			return
+		}
+		departments = append(departments, d)
+	}
+
+	c.JSON(http.StatusOK, departments)
+}
+
+func CreateDepartment(c *gin.Context) {
+	var input DepartmentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Department name is required"})
+		return
+	}
+
+	schoolID := middleware.CurrentUser(c).SchoolID
+	res, err := database.DB.Exec("INSERT INTO departments (school_id, name) VALUES (?, ?)", schoolID, input.Name)
+	if database.IsDuplicate(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A department with this name already exists"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create department: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create department"})
+		return
+	}
+
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{"message": "Department created", "id": id})
+}
+
+func UpdateDepartment(c *gin.Context) {
+	departmentID := c.Param("id")
+	var input DepartmentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Department name is required"})
+		return
+	}
+
+	schoolID := middleware.CurrentUser(c).SchoolID
+	var oldName string
+	err := database.DB.QueryRow(
+		"SELECT name FROM departments WHERE id = ? AND school_id = ?", departmentID, schoolID,
+	).Scan(&oldName)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department"})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update department"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE departments SET name = ? WHERE id = ? AND school_id = ?", input.Name, departmentID, schoolID)
+	if database.IsDuplicate(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A department with this name already exists"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to update department %s: %v", departmentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update department"})
+		return
+	}
+
+	// students and teachers store the department by name, so follow the rename
+	for _, table := range []string{"students", "teachers"} {
+		_, err = tx.Exec("UPDATE "+table+" SET department = ? WHERE department = ? AND school_id = ?", input.Name, oldName, schoolID)
+		if err != nil {
+			log.Printf("[ERROR] Failed to rename department on %s: %v", table, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update department"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update department"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Department updated"})
+}
+
+func DeleteDepartment(c *gin.Context) {
+	departmentID := c.Param("id")
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	var name string
+	var courseCount int
+	err := database.DB.QueryRow(`
+		SELECT d.name, (SELECT COUNT(*) FROM courses WHERE department_id = d.id)
+		FROM departments d
+		WHERE d.id = ? AND d.school_id = ?`,
+		departmentID, schoolID,
+	).Scan(&name, &courseCount)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department"})
+		return
+	}
+	if courseCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Department still has courses", "courses": courseCount})
+		return
+	}
+
+	if _, err := database.DB.Exec("DELETE FROM departments WHERE id = ? AND school_id = ?", departmentID, schoolID); err != nil {
+		log.Printf("[ERROR] Failed to delete department %s: %v", departmentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete department"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Department deleted", "name": name})
+}
+
+// ListSchoolCourses lists the catalog with enrollment and waitlist counts
+// for the current term, or the ?term_id= given.
+func ListSchoolCourses(c *gin.Context) {
+	schoolID := middleware.CurrentUser(c).SchoolID
+	scope, ok := middleware.RequestedTerm(c)
+	if !ok {
+		return
+	}
+	enrolledInTerm, enrolledArgs := scope.Where("sc.term_id")
+	waitingInTerm, waitingArgs := scope.Where("w.term_id")
+
+	args := append(append(enrolledArgs, waitingArgs...), schoolID)
+	rows, err := database.DB.Query(`
+		SELECT c.id, c.school_id, c.name, c.code, c.department_id, COALESCE(d.name, ''),
+		       c.credit_units, c.description, c.active, c.capacity,
+		       (SELECT COUNT(*) FROM student_courses sc WHERE sc.course_id = c.id AND `+enrolledInTerm+`),
+		       (SELECT COUNT(*) FROM course_waitlist w WHERE w.course_id = c.id AND `+waitingInTerm+`),
+		       c.created_at
+		FROM courses c
+		LEFT JOIN departments d ON c.department_id = d.id
+		WHERE c.school_id = ?
+		ORDER BY c.code ASC`,
+		args...,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list courses for school %d: %v", schoolID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch courses"})
+		return
+	}
+	defer rows.Close()
+
+	courses := []models.Course{}
+	for rows.Next() {
+		var course models.Course
+		var departmentID sql.NullInt64
+		if err := rows.Scan(&course.ID, &course.SchoolID, &course.Name, &course.Code, &departmentID, &course.DepartmentName,
+			&course.CreditUnits, &course.Description, &course.Active, &course.Capacity, &course.Enrolled,
+			&course.Waitlisted, &course.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read course data"})
+			return
+		}
+		if departmentID.Valid {
+			id := int(departmentID.Int64)
+			course.DepartmentID = &id
+		}
+		courses = append(courses, course)
+	}
+
+	c.JSON(http.StatusOK, courses)
+}
+
+// validateCourse normalises the input and checks the department belongs to the school.
+func validateCourse(c *gin.Context, input *CourseInput, schoolID int) bool {
+	input.Name = strings.TrimSpace(input.Name)
+	input.Code = strings.ToUpper(strings.TrimSpace(input.Code))
+	input.Description = strings.TrimSpace(input.Description)
+
+	if input.Name == "" || input.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Course name and code are required"})
+		return false
+	}
+	if input.CreditUnits < 0 || input.CreditUnits > 30 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Credit units must be between 0 and 30"})
+		return false
+	}
+	if len(input.Description) > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Description is too long"})
+		return false
+	}
+	if input.Capacity != nil && (*input.Capacity < 0 || *input.Capacity > 10000) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Capacity must be between 0 (unlimited) and 10000"})
+		return false
+	}
+	if input.DepartmentID != nil {
+		var exists bool
+		err := database.DB.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM departments WHERE id = ? AND school_id = ?)",
+			*input.DepartmentID, schoolID,
+		).Scan(&exists)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check department"})
+			return false
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Department not found"})
+			return false
+		}
+	}
+	return true
+}
+
+func CreateCourse(c *gin.Context) {
+	var input CourseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	schoolID := middleware.CurrentUser(c).SchoolID
+	if !validateCourse(c, &input, schoolID) {
+		return
+	}
+	active := input.Active == nil || *input.Active
+	capacity := 0
+	if input.Capacity != nil {
+		capacity = *input.Capacity
+	}
+
+	res, err := database.DB.Exec(`
+		INSERT INTO courses (school_id, name, code, department_id, credit_units, description, active, capacity)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		schoolID, input.Name, input.Code, input.DepartmentID, input.CreditUnits, input.Description, active, capacity,
+	)
+	if database.IsDuplicate(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Course code " + input.Code + " is already in use"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create course: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create course"})
+		return
+	}
+
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{"message": "Course created", "id": id})
+}
+
+func UpdateCourse(c *gin.Context) {
+	courseID := c.Param("id")
+	var input CourseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	schoolID := middleware.CurrentUser(c).SchoolID
+	if !middleware.InSchool(c, middleware.Tenant(c).Course(courseID), "Course") {
+		return
+	}
+	if !validateCourse(c, &input, schoolID) {
+		return
+	}
+
+	_, err := database.DB.Exec(`
+		UPDATE courses
+		SET name = ?, code = ?, department_id = ?, credit_units = ?, description = ?,
+		    active = COALESCE(?, active), capacity = COALESCE(?, capacity)
+		WHERE id = ? AND school_id = ?`,
+		input.Name, input.Code, input.DepartmentID, input.CreditUnits, input.Description, input.Active,
+		input.Capacity, courseID, schoolID,
+	)
+	if database.IsDuplicate(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Course code " + input.Code + " is already in use"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to update course %s: %v", courseID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update course"})
+		return
+	}
+
+	// teacher_courses keeps a copy of the code for display
+	if _, err := database.DB.Exec("UPDATE teacher_courses SET course_code = ? WHERE course_id = ?", input.Code, courseID); err != nil {
+		log.Printf("[ERROR] Failed to sync course code for course %s: %v", courseID, err)
+	}
+	// a larger capacity may free seats for the current term's waitlist
+	if input.Capacity != nil {
+		id, _ := strconv.Atoi(courseID)
+		termID, err := database.CurrentTermID(database.DB, schoolID)
+		if err != nil {
+			log.Printf("[ERROR] Failed to fetch current term of school %d: %v", schoolID, err)
+		} else {
+			fillFreedSeats(id, termID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Course updated"})
+}
+
+func DeleteCourse(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	if !middleware.InSchool(c, middleware.Tenant(c).Course(courseID), "Course") {
+		return
+	}
+
+	// history and overrides count too: they are records, not leftovers
+	var enrollments, schedules, cats, history, overrides int
+	err = database.DB.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM student_courses WHERE course_id = ?),
+			(SELECT COUNT(*) FROM class_schedules WHERE course_id = ?),
+			(SELECT COUNT(*) FROM cats WHERE course_id = ?),
+			(SELECT COUNT(*) FROM enrollment_history WHERE course_id = ?),
+			(SELECT COUNT(*) FROM enrollment_overrides WHERE course_id = ?)`,
+		courseID, courseID, courseID, courseID, courseID,
+	).Scan(&enrollments, &schedules, &cats, &history, &overrides)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check course usage"})
+		return
+	}
+	if enrollments+schedules+cats+history+overrides > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":              "Course is still in use; deactivate it instead",
+			"enrollments":        enrollments,
+			"schedules":          schedules,
+			"cats":               cats,
+			"enrollment_history": history,
+			"overrides":          overrides,
+		})
+		return
+	}
+
+	_, err = database.DB.Exec("DELETE FROM courses WHERE id = ? AND school_id = ?", courseID, middleware.CurrentUser(c).SchoolID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete course %d: %v", courseID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete course"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Course deleted"})
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"database/sql"
 	"net/http"
 	"school-backend/database"
+	"school-backend/middleware"
 	"time"
     "fmt"
 	"github.com/gin-gonic/gin"
@@ -16,6 +18,7 @@ func CreateCat(c *gin.Context) {
 		TeacherID   int       `json:"teacher_id"`
 		CourseName  string    `json:"course_name"`
 		CatDateTime time.Time `json:"cat_datetime"`
+		MaxScore    float64   `json:"max_score"`
 	}
 
 	var input CatInput 
@@ -24,12 +27,54 @@ func CreateCat(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
 		return
 	}
+	// Teachers can only schedule CATs under their own name
+	if user := middleware.CurrentUser(c); user.Role == middleware.RoleTeacher {
+		input.TeacherID = user.ID
+	}
+	tenant := middleware.Tenant(c)
+	if !middleware.InSchool(c, tenant.Course(input.CourseID), "Course") ||
+		!middleware.InSchool(c, tenant.Teacher(input.TeacherID), "Teacher") {
+		return
+	}
+	// a CAT's marks count towards eligibility and promotion, so only the
+	// course's own teachers may set one
+	var teaches bool
+	if err := database.DB.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM teacher_courses WHERE teacher_id = ? AND course_id = ?)",
+		input.TeacherID, input.CourseID,
+	).Scan(&teaches); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check course assignment"})
+		return
+	}
+	if !teaches {
+		if middleware.CurrentUser(c).Role == middleware.RoleTeacher {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't teach this course"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Teacher is not assigned to this course"})
+		}
+		return
+	}
 	// Debug log
 	fmt.Printf("Creating CAT: Course=%d, Teacher=%d, Coursenname=%s, DateTime=%s\n",
 		input.CourseID, input.TeacherID,  input.CourseName, input.CatDateTime.Format(time.RFC3339))
 
-	query := `INSERT INTO cats (course_id, teacher_id, cat_datetime) VALUES (?, ?, ?)`
-	_, err := database.DB.Exec(query, input.CourseID, input.TeacherID, input.CatDateTime)
+	if input.MaxScore < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_score must be positive"})
+		return
+	}
+	if input.MaxScore == 0 {
+		input.MaxScore = 100
+	}
+
+	// the CAT belongs to the term it is sat in
+	termID, err := database.TermOn(database.DB, middleware.CurrentUser(c).SchoolID, input.CatDateTime.Format("2006-01-02"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch term"})
+		return
+	}
+
+	query := `INSERT INTO cats (course_id, teacher_id, cat_datetime, max_score, term_id) VALUES (?, ?, ?, ?, ?)`
+	_, err = database.DB.Exec(query, input.CourseID, input.TeacherID, input.CatDateTime, input.MaxScore, termID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create CAT",
@@ -42,8 +87,16 @@ func CreateCat(c *gin.Context) {
 }
 
 func GetCatsByTeacher(c *gin.Context) {
-    teacherID := c.Param("id")
+    teacherID, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+    if !ok {
+        return
+    }
     slug := c.Param("slug")
+    scope, ok := middleware.RequestedTerm(c)
+    if !ok {
+        return
+    }
+    inTerm, termArgs := scope.Where("cats.term_id")
 
     query := `
     SELECT 
@@ -51,17 +104,19 @@ func GetCatsByTeacher(c *gin.Context) {
         cats.course_id, 
         courses.name AS course_name, 
         cats.teacher_id, 
-        cats.cat_datetime
+        cats.cat_datetime,
+        cats.term_id
     FROM cats
     JOIN courses ON cats.course_id = courses.id
     JOIN teachers t ON cats.teacher_id = t.id
     JOIN schools s ON t.school_id = s.id
     WHERE cats.teacher_id = ?
     AND s.slug = ?
+    AND ` + inTerm + `
     ORDER BY cats.cat_datetime ASC
     `
 
-    rows, err := database.DB.Query(query, teacherID, slug)
+    rows, err := database.DB.Query(query, append([]interface{}{teacherID, slug}, termArgs...)...)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch CATs"})
         return
@@ -74,12 +129,13 @@ func GetCatsByTeacher(c *gin.Context) {
         CourseName  string    `json:"course_name"`
         TeacherID   int       `json:"teacher_id"`
         CatDateTime time.Time `json:"cat_datetime"`
+        TermID      int       `json:"term_id"`
     }
 
     var cats []Cat
     for rows.Next() {
         var cat Cat
-        if err := rows.Scan(&cat.ID, &cat.CourseID, &cat.CourseName, &cat.TeacherID, &cat.CatDateTime); err != nil {
+        if err := rows.Scan(&cat.ID, &cat.CourseID, &cat.CourseName, &cat.TeacherID, &cat.CatDateTime, &cat.TermID); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning CAT row"})
             return
         }
@@ -97,6 +153,9 @@ func GetCatsByTeacher(c *gin.Context) {
 
 func DeleteCat(c *gin.Context) {
 	catID := c.Param("id")
+	if !ownsCat(c, catID) {
+		return
+	}
 	_, err := database.DB.Exec("DELETE FROM cats WHERE id = ?", catID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete CAT"})
@@ -104,6 +163,35 @@ func DeleteCat(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "CAT deleted successfully"})
 }
+// ownsCat checks the CAT belongs to the caller's school and stops teachers
+// from touching CATs scheduled by someone else.
+func ownsCat(c *gin.Context, catID string) bool {
+	if !middleware.InSchool(c, middleware.Tenant(c).Cat(catID), "CAT") {
+		return false
+	}
+
+	user := middleware.CurrentUser(c)
+	if user.Role != middleware.RoleTeacher {
+		return true
+	}
+
+	var teacherID int
+	err := database.DB.QueryRow("SELECT teacher_id FROM cats WHERE id = ?", catID).Scan(&teacherID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "CAT not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch CAT"})
+		return false
+	}
+	if teacherID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return false
+	}
+	return true
+}
+
 type UpdateCatInput struct {
 	NewDateTime time.Time `json:"new_datetime"`
 }
@@ -115,8 +203,17 @@ func UpdateCat(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
+	if !ownsCat(c, catID) {
+		return
+	}
 
-	_, err := database.DB.Exec("UPDATE cats SET cat_datetime = ? WHERE id = ?", input.NewDateTime, catID)
+	termID, err := database.TermOn(database.DB, middleware.CurrentUser(c).SchoolID, input.NewDateTime.Format("2006-01-02"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch term"})
+		return
+	}
+
+	_, err = database.DB.Exec("UPDATE cats SET cat_datetime = ?, term_id = ? WHERE id = ?", input.NewDateTime, termID, catID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update CAT"})
 		return
@@ -126,18 +223,29 @@ func UpdateCat(c *gin.Context) {
 
 
 func GetCatsForStudent(c *gin.Context) {
-	studentID := c.Param("id")
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+	scope, ok := middleware.RequestedTerm(c)
+	if !ok {
+		return
+	}
+	inTerm, termArgs := scope.Where("cats.term_id")
 
+	// a student sits a course's CATs for the term they took it in
 	query := `
-	SELECT cats.id, cats.course_id, courses.name AS course_name, cats.teacher_id, cats.cat_datetime
+	SELECT cats.id, cats.course_id, courses.name AS course_name, cats.teacher_id, cats.cat_datetime,
+	       cats.max_score, cats.status, cat_marks.score, cats.term_id
 	FROM cats
 	JOIN courses ON cats.course_id = courses.id
-	JOIN student_courses ON cats.course_id = student_courses.course_id
-	WHERE student_courses.student_id = ?
+	JOIN student_courses ON cats.course_id = student_courses.course_id AND cats.term_id = student_courses.term_id
+	LEFT JOIN cat_marks ON cat_marks.cat_id = cats.id AND cat_marks.student_id = student_courses.student_id
+	WHERE student_courses.student_id = ? AND ` + inTerm + `
 	ORDER BY cats.cat_datetime ASC
 	`
 
-	rows, err := database.DB.Query(query, studentID)
+	rows, err := database.DB.Query(query, append([]interface{}{studentID}, termArgs...)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch CATs for student"})
 		return
@@ -150,17 +258,69 @@ func GetCatsForStudent(c *gin.Context) {
 		CourseName  string    `json:"course_name"`
 		TeacherID   int       `json:"teacher_id"`
 		CatDateTime time.Time `json:"cat_datetime"`
+		MaxScore    float64   `json:"max_score"`
+		TermID      int       `json:"term_id"`
+		Published   bool      `json:"published"`
+		Score       *float64  `json:"score,omitempty"`
+		Rank        int       `json:"rank,omitempty"`
+		Stats       *CatStats `json:"stats,omitempty"`
 	}
 
 	var cats []Cat
 	for rows.Next() {
 		var cat Cat
-		if err := rows.Scan(&cat.ID, &cat.CourseID, &cat.CourseName, &cat.TeacherID, &cat.CatDateTime); err != nil {
+		var status string
+		var score sql.NullFloat64
+		if err := rows.Scan(&cat.ID, &cat.CourseID, &cat.CourseName, &cat.TeacherID, &cat.CatDateTime,
+			&cat.MaxScore, &status, &score, &cat.TermID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning CAT row"})
 			return
 		}
+		// marks stay hidden until the teacher publishes them
+		cat.Published = status == CatPublished
+		if cat.Published && score.Valid {
+			cat.Score = &score.Float64
+		}
 		cats = append(cats, cat)
 	}
+	rows.Close()
+
+	// class statistics for every published CAT the student sits
+	classScores := make(map[int][]float64)
+	scoreRows, err := database.DB.Query(`
+	SELECT cat_marks.cat_id, cat_marks.score
+	FROM cat_marks
+	JOIN cats ON cat_marks.cat_id = cats.id
+	JOIN student_courses ON cats.course_id = student_courses.course_id AND cats.term_id = student_courses.term_id
+	WHERE student_courses.student_id = ? AND cats.status = ? AND `+inTerm,
+		append([]interface{}{studentID, CatPublished}, termArgs...)...,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch class statistics"})
+		return
+	}
+	defer scoreRows.Close()
+	for scoreRows.Next() {
+		var catID int
+		var score float64
+		if err := scoreRows.Scan(&catID, &score); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read class statistics"})
+			return
+		}
+		classScores[catID] = append(classScores[catID], score)
+	}
+
+	for i := range cats {
+		scores, ok := classScores[cats[i].ID]
+		if !cats[i].Published || !ok {
+			continue
+		}
+		stats := computeStats(scores)
+		cats[i].Stats = &stats
+		if cats[i].Score != nil {
+			cats[i].Rank = rankOf(*cats[i].Score, scores)
+		}
+	}
 
 	c.JSON(http.StatusOK, cats)
 }
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"school-backend/config"
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const checkinQRSize = 512 // pixels
+
+// issueCheckinCode signs a fresh code for an attendance session that is taking
+// place today. It writes the error response itself.
+func issueCheckinCode(c *gin.Context) (utils.CheckinCode, string, bool) {
+	sessionID := c.Param("id")
+	if !ownsAttendanceSession(c, sessionID) {
+		return utils.CheckinCode{}, "", false
+	}
+
+	var scheduleID sql.NullInt64
+	var date time.Time
+	err := database.DB.QueryRow(
+		"SELECT schedule_id, session_date FROM attendance_sessions WHERE id = ?", sessionID,
+	).Scan(&scheduleID, &date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance session"})
+		return utils.CheckinCode{}, "", false
+	}
+	if !scheduleID.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "The class slot for this session no longer exists"})
+		return utils.CheckinCode{}, "", false
+	}
+	today := time.Now().Format("2006-01-02")
+	if date.Format("2006-01-02") != today {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Check-in codes can only be issued on the day of the class"})
+		return utils.CheckinCode{}, "", false
+	}
+
+	code := utils.CheckinCode{
+		ScheduleID: int(scheduleID.Int64),
+		Date:       today,
+		Expires:    time.Now().Add(config.App.CheckinWindow),
+	}
+	return code, utils.SignCheckinCode(code), true
+}
+
+// GetCheckinCode returns a signed check-in code as text, for clients that draw
+// their own QR code or want to show it for manual entry.
+func GetCheckinCode(c *gin.Context) {
+	code, signed, ok := issueCheckinCode(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": signed, "expires_at": code.Expires})
+}
+
+// GetCheckinQR renders a fresh check-in code as a PNG for the teacher to
+// project. Each request issues a new code.
+func GetCheckinQR(c *gin.Context) {
+	code, signed, ok := issueCheckinCode(c)
+	if !ok {
+		return
+	}
+	png, err := qrcode.Encode(signed, qrcode.Medium, checkinQRSize)
+	if err != nil {
+		log.Printf("[ERROR] Failed to render check-in QR code: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Checkin-Expires", code.Expires.UTC().Format(time.RFC3339))
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// CheckIn marks the calling student present from a scanned code. A code only
+// works for the slot and date it was issued for, before it expires, and only
+// for students enrolled in the course.
+func CheckIn(c *gin.Context) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	code, err := utils.ParseCheckinCode(input.Code)
+	if errors.Is(err, utils.ErrCheckinExpired) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This check-in code has expired"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid check-in code"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	var sessionID, courseID, termID int
+	err = database.DB.QueryRow(
+		"SELECT id, course_id, term_id FROM attendance_sessions WHERE schedule_id = ? AND session_date = ? AND school_id = ?",
+		code.ScheduleID, code.Date, user.SchoolID,
+	).Scan(&sessionID, &courseID, &termID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attendance session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance session"})
+		return
+	}
+
+	var enrolled bool
+	err = database.DB.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM student_courses WHERE student_id = ? AND course_id = ? AND term_id = ?)",
+		user.ID, courseID, termID,
+	).Scan(&enrolled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollment"})
+		return
+	}
+	if !enrolled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not enrolled in this course"})
+		return
+	}
+
+	// A scan proves presence, so it overrides "absent" but leaves a teacher's
+	// "late" or "excused" alone. status is assigned last because MySQL
+	// evaluates the other assignments against the old value.
+	_, err = database.DB.Exec(`
+		INSERT INTO attendance_records (session_id, student_id, status, recorded_by, checked_in_at)
+		VALUES (?, ?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE
+			checked_in_at = IF(status = ?, VALUES(checked_in_at), checked_in_at),
+			recorded_by = IF(status = ?, VALUES(recorded_by), recorded_by),
+			status = IF(status = ?, VALUES(status), status)`,
+		sessionID, user.ID, AttendancePresent, user.ID,
+		AttendanceAbsent, AttendanceAbsent, AttendanceAbsent,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to check in student %d to session %d: %v", user.ID, sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in"})
+		return
+	}
+
+	var status string
+	if err := database.DB.QueryRow(
+		"SELECT status FROM attendance_records WHERE session_id = ? AND student_id = ?", sessionID, user.ID,
+	).Scan(&status); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Checked in", "session_id": sessionID, "status": status})
+}
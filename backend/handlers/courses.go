@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"school-backend/database"
+	"school-backend/middleware"
     "fmt"
 	"github.com/gin-gonic/gin"
 )
@@ -13,7 +14,10 @@ type AssignCoursesPayload struct {
 }
 
 func AssignCoursesToTeacher(c *gin.Context) {
-    teacherID := c.Param("id")
+    teacherID, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+    if !ok {
+        return
+    }
     var payload AssignCoursesPayload
     if err := c.BindJSON(&payload); 
 	err != nil {
@@ -37,9 +41,12 @@ func AssignCoursesToTeacher(c *gin.Context) {
         var courseCode string
 
         // Fetch course code
-        err := database.DB.QueryRow("SELECT code FROM courses WHERE id = ?", courseID).Scan(&courseCode)
+        err := database.DB.QueryRow(
+            "SELECT code FROM courses WHERE id = ? AND school_id = ?",
+            courseID, middleware.CurrentUser(c).SchoolID,
+        ).Scan(&courseCode)
         if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Course not found"})
+            c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
             return
         }
 
@@ -75,7 +82,10 @@ func AssignCoursesToTeacher(c *gin.Context) {
 
 
 func DeleteAssignedCourse(c *gin.Context) {
-    teacherID := c.Param("id")
+    teacherID, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+    if !ok {
+        return
+    }
     courseID := c.Param("courseId")
 
     // First, check if assignment exists
@@ -122,7 +132,10 @@ func DeleteAssignedCourse(c *gin.Context) {
 
 
 func GetAllCourses(c *gin.Context) {
-    rows, err := database.DB.Query("SELECT id, name, code FROM courses")
+    rows, err := database.DB.Query(
+        "SELECT id, name, code FROM courses WHERE school_id = ? AND active = 1",
+        middleware.CurrentUser(c).SchoolID,
+    )
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch courses"})
         return
@@ -153,14 +166,21 @@ func GetAllCourses(c *gin.Context) {
 
 func GetCoursesByDepartment(c *gin.Context) {
     department := c.Param("department")
+    schoolID := middleware.CurrentUser(c).SchoolID
 
     var departmentID int
-    err := database.DB.QueryRow("SELECT id FROM departments WHERE name = ?", department).Scan(&departmentID)
+    err := database.DB.QueryRow(
+        "SELECT id FROM departments WHERE name = ? AND school_id = ?",
+        department, schoolID,
+    ).Scan(&departmentID)
     if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
         return
     }
-    rows, err := database.DB.Query("SELECT id, name, code FROM courses WHERE department_id = ?", departmentID)
+    rows, err := database.DB.Query(
+        "SELECT id, name, code FROM courses WHERE department_id = ? AND school_id = ? AND active = 1",
+        departmentID, schoolID,
+    )
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch courses"})
         return
@@ -182,8 +202,11 @@ func GetCoursesByDepartment(c *gin.Context) {
 
 
 func GetTeacherCourses(c *gin.Context) {
-	teacherID := c.Param("id")
-	log.Printf("[INFO] Fetching courses for teacher ID: %s", teacherID)
+	teacherID, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+	if !ok {
+		return
+	}
+	log.Printf("[INFO] Fetching courses for teacher ID: %d", teacherID)
 
 	query := `
 		SELECT c.id, c.name, c.code
@@ -194,7 +217,7 @@ func GetTeacherCourses(c *gin.Context) {
 
 	rows, err := database.DB.Query(query, teacherID)
 	if err != nil {
-		log.Printf("[ERROR] Query failed for teacher ID %s: %v", teacherID, err)
+		log.Printf("[ERROR] Query failed for teacher ID %d: %v", teacherID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch courses"})
 		return
 	}
@@ -211,7 +234,7 @@ func GetTeacherCourses(c *gin.Context) {
 	for rows.Next() {
 		var course Course
 		if err := rows.Scan(&course.ID, &course.Name, &course.Code); err != nil {
-			log.Printf("[ERROR] Row scan failed for teacher ID %s: %v", teacherID, err)
+			log.Printf("[ERROR] Row scan failed for teacher ID %d: %v", teacherID, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read course data"})
 			return
 		}
@@ -219,18 +242,26 @@ func GetTeacherCourses(c *gin.Context) {
 		courses = append(courses, course)
 	}
 
-	log.Printf("[INFO] Returning %d courses for teacher ID %s", len(courses), teacherID)
+	log.Printf("[INFO] Returning %d courses for teacher ID %d", len(courses), teacherID)
 	c.JSON(http.StatusOK, courses)
 }
 
 
 func GetTeacherCoursesy(c *gin.Context) {
-	teacherID := c.Param("id")
+	teacherID, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+	if !ok {
+		return
+	}
+	scope, ok := middleware.RequestedTerm(c)
+	if !ok {
+		return
+	}
+	inTerm, termArgs := scope.Where("sc.term_id")
 
 	query := `
 	SELECT 
 		c.id, c.name, c.code, 
-		(SELECT COUNT(*) FROM student_courses sc WHERE sc.course_id = c.id) AS student_count
+		(SELECT COUNT(*) FROM student_courses sc WHERE sc.course_id = c.id AND ` + inTerm + `) AS student_count
 	FROM 
 		courses c
 	JOIN 
@@ -239,7 +270,7 @@ func GetTeacherCoursesy(c *gin.Context) {
 		tc.teacher_id = ?
 	`
 
-	rows, err := database.DB.Query(query, teacherID)
+	rows, err := database.DB.Query(query, append(termArgs, teacherID)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch courses"})
 		return
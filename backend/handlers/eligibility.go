@@ -0,0 +1,597 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requirementPrerequisite = "prerequisite"
+	requirementCorequisite  = "corequisite"
+)
+
+// Unmet is one requirement a student doesn't meet for a course.
+type Unmet struct {
+	Kind     string `json:"kind"`                // year, department, prerequisite or corequisite
+	CourseID int    `json:"course_id,omitempty"` // the required course, for (co)requisites
+	Message  string `json:"message"`
+}
+
+// CourseEligibility is the outcome of checking one course for a student.
+type CourseEligibility struct {
+	CourseID int     `json:"course_id"`
+	Eligible bool    `json:"eligible"`
+	Unmet    []Unmet `json:"unmet"`
+}
+
+// studentYear reads the number out of a year such as "2" or "Year 2"; 0 when
+// there is none.
+func studentYear(year string) int {
+	start := strings.IndexAny(year, "0123456789")
+	if start < 0 {
+		return 0
+	}
+	end := start
+	for end < len(year) && year[end] >= '0' && year[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(year[start:end])
+	return n
+}
+
+// checkEligibility works out, for each course, which requirements the student
+// doesn't meet to enroll in it in the given term. A prerequisite counts as met
+// once the student has taken it in an earlier term, or passed it (by the
+// promotion pass mark) in any term but this one; being enrolled in it in the
+// same term is not enough. A corequisite only needs an enrollment, and may
+// also be one of the courses being enrolled in now. The result has an entry
+// for every course.
+func checkEligibility(q querier, studentID, termID int, courseIDs []int) (map[int][]Unmet, error) {
+	result := map[int][]Unmet{}
+	if len(courseIDs) == 0 {
+		return result, nil
+	}
+	requested := map[int]bool{}
+	for _, id := range courseIDs {
+		result[id] = []Unmet{}
+		requested[id] = true
+	}
+
+	var schoolID int
+	var department, yearText string
+	if err := q.QueryRow(
+		"SELECT school_id, department, year FROM students WHERE id = ?", studentID,
+	).Scan(&schoolID, &department, &yearText); err != nil {
+		return nil, fmt.Errorf("load student %d: %w", studentID, err)
+	}
+	year := studentYear(yearText)
+	rules, err := promotionRules(q, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	enrolled, err := courseSet(q, "SELECT course_id FROM student_courses WHERE student_id = ?", studentID)
+	if err != nil {
+		return nil, err
+	}
+	// enrollments from before the school had terms count as earlier
+	completed, err := courseSet(q, `
+		SELECT sc.course_id
+		FROM student_courses sc
+		JOIN semesters t ON t.id = ?
+		LEFT JOIN semesters s ON s.id = sc.term_id
+		WHERE sc.student_id = ? AND (s.id IS NULL OR s.starts_on < t.starts_on)`,
+		termID, studentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	passed, err := courseSet(q, `
+		SELECT sc.course_id
+		FROM student_courses sc
+		JOIN cats ON cats.course_id = sc.course_id AND cats.term_id = sc.term_id AND cats.status = ?
+		LEFT JOIN cat_marks cm ON cm.cat_id = cats.id AND cm.student_id = sc.student_id
+		WHERE sc.student_id = ? AND (? = 0 OR sc.term_id <> ?)
+		GROUP BY sc.course_id, sc.term_id
+		HAVING SUM(cats.max_score) > 0 AND SUM(COALESCE(cm.score, 0)) * 100 >= SUM(cats.max_score) * ?`,
+		CatPublished, studentID, termID, termID, rules.PassMark,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for id := range passed {
+		completed[id] = true
+	}
+
+	placeholders, args := inClause(courseIDs)
+
+	rows, err := q.Query("SELECT id, min_year, max_year FROM courses WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id, min, max int
+		if err := rows.Scan(&id, &min, &max); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		switch {
+		case min > 0 && year < min && max > 0:
+			result[id] = append(result[id], Unmet{Kind: "year", Message: fmt.Sprintf("Open to years %d to %d", min, max)})
+		case min > 0 && year < min:
+			result[id] = append(result[id], Unmet{Kind: "year", Message: fmt.Sprintf("Open to year %d and above", min)})
+		case max > 0 && year > max:
+			result[id] = append(result[id], Unmet{Kind: "year", Message: fmt.Sprintf("Open to year %d and below", max)})
+		}
+	}
+	rows.Close()
+
+	allowed := map[int][]string{}
+	rows, err = q.Query(`
+		SELECT cd.course_id, d.name
+		FROM course_departments cd
+		JOIN departments d ON cd.department_id = d.id
+		WHERE cd.course_id IN (`+placeholders+`)
+		ORDER BY d.name ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		allowed[id] = append(allowed[id], name)
+	}
+	rows.Close()
+	for id, names := range allowed {
+		ok := false
+		for _, name := range names {
+			ok = ok || strings.EqualFold(name, department)
+		}
+		if !ok {
+			result[id] = append(result[id], Unmet{Kind: "department", Message: "Only open to " + strings.Join(names, ", ")})
+		}
+	}
+
+	rows, err = q.Query(`
+		SELECT r.course_id, r.required_course_id, r.kind, c.code
+		FROM course_requirements r
+		JOIN courses c ON r.required_course_id = c.id
+		WHERE r.course_id IN (`+placeholders+`)
+		ORDER BY c.code ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, required int
+		var kind, code string
+		if err := rows.Scan(&id, &required, &kind, &code); err != nil {
+			return nil, err
+		}
+		switch {
+		case completed[required]:
+		case kind == requirementCorequisite && (enrolled[required] || requested[required]):
+		case kind == requirementCorequisite:
+			result[id] = append(result[id], Unmet{Kind: kind, CourseID: required, Message: "Take " + code + " before or alongside this course"})
+		default:
+			result[id] = append(result[id], Unmet{Kind: kind, CourseID: required, Message: "Requires " + code})
+		}
+	}
+	return result, rows.Err()
+}
+
+// courseSet collects the course ids a query returns.
+func courseSet(q querier, query string, args ...interface{}) (map[int]bool, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	set := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		set[id] = true
+	}
+	return set, rows.Err()
+}
+
+// eligibilityList turns checkEligibility's map into a list in course order.
+func eligibilityList(courseIDs []int, unmet map[int][]Unmet) []CourseEligibility {
+	list := make([]CourseEligibility, 0, len(courseIDs))
+	for _, id := range courseIDs {
+		list = append(list, CourseEligibility{CourseID: id, Eligible: len(unmet[id]) == 0, Unmet: unmet[id]})
+	}
+	return list
+}
+
+// recordOverride keeps an audit row for an enrollment pushed through despite
+// unmet requirements.
+func recordOverride(db execer, studentID, courseID int, user middleware.Principal, reason string, unmet []Unmet) error {
+	detail, err := json.Marshal(unmet)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO enrollment_overrides (student_id, course_id, overridden_by, overridden_by_role, reason, unmet)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		studentID, courseID, user.ID, user.Role, reason, string(detail),
+	)
+	return err
+}
+
+// GetStudentEligibility reports which courses a student may enroll in, in the
+// current term or the ?term_id= given. It checks the course_ids given (comma
+// separated), or every active course of the school the student isn't enrolled
+// in that term.
+func GetStudentEligibility(c *gin.Context) {
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+	termID, ok := singleTerm(c)
+	if !ok {
+		return
+	}
+
+	var courseIDs []int
+	if list := c.Query("course_ids"); list != "" {
+		tenant := middleware.Tenant(c)
+		for _, part := range strings.Split(list, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "course_ids must be a comma-separated list of ids"})
+				return
+			}
+			if !middleware.InSchool(c, tenant.Course(id), "Course") {
+				return
+			}
+			courseIDs = append(courseIDs, id)
+		}
+		courseIDs = uniqueInts(courseIDs)
+	} else {
+		rows, err := database.DB.Query(`
+			SELECT id FROM courses
+			WHERE school_id = ? AND active = 1
+			  AND id NOT IN (SELECT course_id FROM student_courses WHERE student_id = ? AND term_id = ?)
+			ORDER BY code ASC`,
+			middleware.CurrentUser(c).SchoolID, studentID, termID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch courses"})
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read courses"})
+				return
+			}
+			courseIDs = append(courseIDs, id)
+		}
+	}
+
+	unmet, err := checkEligibility(database.DB, studentID, termID, courseIDs)
+	if err != nil {
+		log.Printf("[ERROR] Eligibility check for student %d failed: %v", studentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check eligibility"})
+		return
+	}
+	c.JSON(http.StatusOK, eligibilityList(courseIDs, unmet))
+}
+
+type CourseRequirementsInput struct {
+	MinYear       int   `json:"min_year"`
+	MaxYear       int   `json:"max_year"`
+	DepartmentIDs []int `json:"department_ids"`
+	Prerequisites []int `json:"prerequisites"`
+	Corequisites  []int `json:"corequisites"`
+}
+
+// GetCourseRequirements shows a course's eligibility rules.
+func GetCourseRequirements(c *gin.Context) {
+	courseID := c.Param("id")
+	if !middleware.InSchool(c, middleware.Tenant(c).Course(courseID), "Course") {
+		return
+	}
+
+	var minYear, maxYear int
+	if err := database.DB.QueryRow(
+		"SELECT min_year, max_year FROM courses WHERE id = ?", courseID,
+	).Scan(&minYear, &maxYear); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+		return
+	}
+
+	type Ref struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+		Code string `json:"code,omitempty"`
+	}
+	departments := []Ref{}
+	rows, err := database.DB.Query(`
+		SELECT d.id, d.name FROM course_departments cd
+		JOIN departments d ON cd.department_id = d.id
+		WHERE cd.course_id = ? ORDER BY d.name ASC`,
+		courseID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch departments"})
+		return
+	}
+	for rows.Next() {
+		var d Ref
+		if err := rows.Scan(&d.ID, &d.Name); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read departments"})
+			return
+		}
+		departments = append(departments, d)
+	}
+	rows.Close()
+
+	prerequisites, corequisites := []Ref{}, []Ref{}
+	rows, err = database.DB.Query(`
+		SELECT c.id, c.name, c.code, r.kind FROM course_requirements r
+		JOIN courses c ON r.required_course_id = c.id
+		WHERE r.course_id = ? ORDER BY c.code ASC`,
+		courseID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch requirements"})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r Ref
+		var kind string
+		if err := rows.Scan(&r.ID, &r.Name, &r.Code, &kind); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read requirements"})
+			return
+		}
+		if kind == requirementCorequisite {
+			corequisites = append(corequisites, r)
+		} else {
+			prerequisites = append(prerequisites, r)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"min_year":      minYear,
+		"max_year":      maxYear,
+		"departments":   departments,
+		"prerequisites": prerequisites,
+		"corequisites":  corequisites,
+	})
+}
+
+// SetCourseRequirements replaces a course's eligibility rules. Prerequisite
+// chains that loop back to the course are refused, since nobody could ever
+// take it.
+func SetCourseRequirements(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	var input CourseRequirementsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	tenant := middleware.Tenant(c)
+	if !middleware.InSchool(c, tenant.Course(courseID), "Course") {
+		return
+	}
+	if input.MinYear < 0 || input.MaxYear < 0 || (input.MaxYear > 0 && input.MinYear > input.MaxYear) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_year and max_year must be 0 (no limit) or a valid range"})
+		return
+	}
+
+	input.Prerequisites = uniqueInts(input.Prerequisites)
+	input.Corequisites = uniqueInts(input.Corequisites)
+	kinds := map[int]string{}
+	for _, id := range input.Prerequisites {
+		kinds[id] = requirementPrerequisite
+	}
+	for _, id := range input.Corequisites {
+		if kinds[id] != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A course can't be both a prerequisite and a corequisite"})
+			return
+		}
+		kinds[id] = requirementCorequisite
+	}
+	for id := range kinds {
+		if id == courseID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A course can't require itself"})
+			return
+		}
+		if !middleware.InSchool(c, tenant.Course(id), "Required course") {
+			return
+		}
+	}
+
+	schoolID := middleware.CurrentUser(c).SchoolID
+	departmentIDs := uniqueInts(input.DepartmentIDs)
+	for _, id := range departmentIDs {
+		var exists bool
+		if err := database.DB.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM departments WHERE id = ? AND school_id = ?)", id, schoolID,
+		).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check department"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Department not found"})
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save requirements"})
+		return
+	}
+	defer tx.Rollback()
+
+	fail := func(err error) {
+		log.Printf("[ERROR] Failed to save requirements of course %d: %v", courseID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save requirements"})
+	}
+	if _, err := tx.Exec("UPDATE courses SET min_year = ?, max_year = ? WHERE id = ?", input.MinYear, input.MaxYear, courseID); err != nil {
+		fail(err)
+		return
+	}
+	for _, q := range []string{
+		"DELETE FROM course_departments WHERE course_id = ?",
+		"DELETE FROM course_requirements WHERE course_id = ?",
+	} {
+		if _, err := tx.Exec(q, courseID); err != nil {
+			fail(err)
+			return
+		}
+	}
+	for _, id := range departmentIDs {
+		if _, err := tx.Exec("INSERT INTO course_departments (course_id, department_id) VALUES (?, ?)", courseID, id); err != nil {
+			fail(err)
+			return
+		}
+	}
+	for id, kind := range kinds {
+		if _, err := tx.Exec(
+			"INSERT INTO course_requirements (course_id, required_course_id, kind) VALUES (?, ?, ?)", courseID, id, kind,
+		); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	cyclic, err := requirementCycle(tx, schoolID, courseID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if cyclic {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "These requirements would make the course require itself through another course"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		fail(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Course requirements saved"})
+}
+
+// requirementCycle reports whether following the requirements of courseID
+// ever leads back to it.
+func requirementCycle(tx *sql.Tx, schoolID, courseID int) (bool, error) {
+	rows, err := tx.Query(`
+		SELECT r.course_id, r.required_course_id
+		FROM course_requirements r
+		JOIN courses c ON r.course_id = c.id
+		WHERE c.school_id = ?`,
+		schoolID,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	edges := map[int][]int{}
+	for rows.Next() {
+		var from, to int
+		if err := rows.Scan(&from, &to); err != nil {
+			return false, err
+		}
+		edges[from] = append(edges[from], to)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	seen := map[int]bool{}
+	stack := append([]int{}, edges[courseID]...)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == courseID {
+			return true, nil
+		}
+		if !seen[id] {
+			seen[id] = true
+			stack = append(stack, edges[id]...)
+		}
+	}
+	return false, nil
+}
+
+// ListEnrollmentOverrides is the audit trail of enrollments made despite
+// unmet requirements.
+func ListEnrollmentOverrides(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT o.id, o.student_id, s.fullname, o.course_id, c.code, o.overridden_by, o.overridden_by_role,
+		       o.reason, o.unmet, o.created_at
+		FROM enrollment_overrides o
+		JOIN students s ON o.student_id = s.id
+		JOIN courses c ON o.course_id = c.id
+		WHERE s.school_id = ?
+		ORDER BY o.created_at DESC`,
+		middleware.CurrentUser(c).SchoolID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch overrides"})
+		return
+	}
+	defer rows.Close()
+
+	type Override struct {
+		ID               int       `json:"id"`
+		StudentID        int       `json:"student_id"`
+		StudentName      string    `json:"student_name"`
+		CourseID         int       `json:"course_id"`
+		CourseCode       string    `json:"course_code"`
+		OverriddenBy     int       `json:"overridden_by"`
+		OverriddenByRole string    `json:"overridden_by_role"`
+		Reason           string    `json:"reason"`
+		Unmet            []Unmet   `json:"unmet"`
+		CreatedAt        time.Time `json:"created_at"`
+	}
+
+	overrides := []Override{}
+	for rows.Next() {
+		var o Override
+		var unmet string
+		if err := rows.Scan(&o.ID, &o.StudentID, &o.StudentName, &o.CourseID, &o.CourseCode, &o.OverriddenBy,
+			&o.OverriddenByRole, &o.Reason, &unmet, &o.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read overrides"})
+			return
+		}
+		if err := json.Unmarshal([]byte(unmet), &o.Unmet); err != nil {
+			o.Unmet = []Unmet{}
+		}
+		overrides = append(overrides, o)
+	}
+
+	c.JSON(http.StatusOK, overrides)
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"school-backend/database"
+	"school-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BulkEnroll enrolls every active student of a department and year in a set
+// of courses. Without commit=true it only previews who would be affected.
+// Existing enrollments are skipped, as are courses a student doesn't meet the
+// requirements of unless override is set with a reason, and students past a
+// course's capacity join its waitlist. A commit does the work in one
+// transaction. A preview writes and locks nothing: it reads the courses' seats
+// once and hands them out as the commit would, so it shows who would get a
+// seat unless enrollments change in between. Enrollments go into the current
+// term unless ?term_id= names another.
+func BulkEnroll(c *gin.Context) {
+	var input struct {
+		Department string `json:"department"`
+		Year       string `json:"year"`
+		CourseIDs  []int  `json:"course_ids"`
+		Override   bool   `json:"override"`
+		Reason     string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input.Department = strings.TrimSpace(input.Department)
+	input.Year = strings.TrimSpace(input.Year)
+	if input.Department == "" || input.Year == "" || len(input.CourseIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "department, year and course_ids are required"})
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Override && input.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to override course requirements"})
+		return
+	}
+	if len(input.Reason) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason must be at most 500 characters"})
+		return
+	}
+	courseIDs := uniqueInts(input.CourseIDs)
+	tenant := middleware.Tenant(c)
+	for _, id := range courseIDs {
+		if !middleware.InSchool(c, tenant.Course(id), "Course") {
+			return
+		}
+	}
+	commit := c.Query("commit") == "true"
+	termID, ok := singleTerm(c)
+	if !ok {
+		return
+	}
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll students"})
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, fullname, username, registrationNumber
+		FROM students
+		WHERE school_id = ? AND department = ? AND year = ? AND status = ?
+		ORDER BY fullname ASC`,
+		schoolID, input.Department, input.Year, accountActive,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch students"})
+		return
+	}
+
+	type Student struct {
+		ID                 int    `json:"id"`
+		FullName           string `json:"fullname"`
+		Username           string `json:"username"`
+		RegistrationNumber string `json:"registrationNumber"`
+		// course ids the student would be or was newly enrolled in
+		Enroll []int `json:"enroll"`
+		// course ids the student already had
+		AlreadyEnrolled []int `json:"already_enrolled"`
+		// courses whose requirements the student doesn't meet; enrolled
+		// anyway when overriding
+		Ineligible []CourseEligibility `json:"ineligible"`
+		// full courses the student was queued for
+		Waitlisted []int `json:"waitlisted"`
+	}
+	students := []*Student{}
+	byID := map[int]*Student{}
+	for rows.Next() {
+		s := &Student{Enroll: []int{}, AlreadyEnrolled: []int{}, Ineligible: []CourseEligibility{}, Waitlisted: []int{}}
+		if err := rows.Scan(&s.ID, &s.FullName, &s.Username, &s.RegistrationNumber); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read students"})
+			return
+		}
+		students = append(students, s)
+		byID[s.ID] = s
+	}
+	rows.Close()
+
+	placeholders, args := inClause(courseIDs)
+	existing, err := tx.Query(`
+		SELECT sc.student_id, sc.course_id
+		FROM student_courses sc
+		JOIN students s ON sc.student_id = s.id
+		WHERE s.school_id = ? AND s.department = ? AND s.year = ? AND s.status = ? AND sc.term_id = ?
+		  AND sc.course_id IN (`+placeholders+`)`,
+		append([]interface{}{schoolID, input.Department, input.Year, accountActive, termID}, args...)...,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollments"})
+		return
+	}
+	has := map[[2]int]bool{}
+	for existing.Next() {
+		var sid, cid int
+		if err := existing.Scan(&sid, &cid); err != nil {
+			existing.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read enrollments"})
+			return
+		}
+		has[[2]int{sid, cid}] = true
+	}
+	existing.Close()
+
+	var plans map[int]*seatPlan
+	if !commit {
+		if plans, err = planSeats(tx, courseIDs, termID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch courses"})
+			return
+		}
+	}
+
+	user := middleware.CurrentUser(c)
+	inserted, skipped, ineligible, waitlisted := 0, 0, 0, 0
+	for _, s := range students {
+		var pending []int
+		for _, cid := range courseIDs {
+			if has[[2]int{s.ID, cid}] {
+				s.AlreadyEnrolled = append(s.AlreadyEnrolled, cid)
+				skipped++
+				continue
+			}
+			pending = append(pending, cid)
+		}
+		unmet, err := checkEligibility(tx, s.ID, termID, pending)
+		if err != nil {
+			log.Printf("[ERROR] Eligibility check for student %d failed: %v", s.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check eligibility"})
+			return
+		}
+		for _, cid := range pending {
+			if len(unmet[cid]) > 0 {
+				s.Ineligible = append(s.Ineligible, CourseEligibility{CourseID: cid, Unmet: unmet[cid]})
+				ineligible++
+				if !input.Override {
+					continue
+				}
+			}
+			var seated bool
+			if commit {
+				position, err := enrollOrWaitlist(tx, s.ID, cid, termID, user, "Bulk enrollment of "+input.Department+" year "+input.Year)
+				if err != nil {
+					log.Printf("[ERROR] Bulk enrollment of student %d in course %d failed: %v", s.ID, cid, err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll students, nothing was changed"})
+					return
+				}
+				if len(unmet[cid]) > 0 {
+					if err := recordOverride(tx, s.ID, cid, user, input.Reason, unmet[cid]); err != nil {
+						log.Printf("[ERROR] Failed to record override for student %d course %d: %v", s.ID, cid, err)
+						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll students, nothing was changed"})
+						return
+					}
+				}
+				seated = position == 0
+			} else {
+				seated = plans[cid].take(s.ID)
+			}
+			if !seated {
+				s.Waitlisted = append(s.Waitlisted, cid)
+				waitlisted++
+				continue
+			}
+			s.Enroll = append(s.Enroll, cid)
+			inserted++
+		}
+	}
+
+	if commit {
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll students, nothing was changed"})
+			return
+		}
+		log.Printf("[INFO] %s %d bulk enrolled %s year %s in %v: %d inserted, %d waitlisted, %d skipped, %d ineligible (override %t)",
+			user.Role, user.ID, input.Department, input.Year, courseIDs, inserted, waitlisted, skipped, ineligible, input.Override)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run":    !commit,
+		"term_id":    termID,
+		"department": input.Department,
+		"year":       input.Year,
+		"course_ids": courseIDs,
+		"students":   students,
+		"inserted":   inserted,
+		"waitlisted": waitlisted,
+		"skipped":    skipped,
+		"ineligible": ineligible,
+		"override":   input.Override,
+	})
+}
+
+func uniqueInts(ids []int) []int {
+	seen := map[int]bool{}
+	out := []int{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// inClause returns "?, ?, ?" and the matching arguments for an IN (...) list.
+func inClause(ids []int) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/models"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Amounts are DECIMAL(12,2) in the database; round in Go so sums of floats
+// don't leak fractions of a cent into responses.
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// CreateFinanceAccount lets a school owner add a finance staff member.
+func CreateFinanceAccount(c *gin.Context) {
+	var req struct {
+		FullName    string `json:"fullname"`
+		Email       string `json:"email"`
+		Password    string `json:"password"`
+		PhoneNumber string `json:"phonenumber"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.FullName == "" || req.Email == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fullname, email and password are required"})
+		return
+	}
+	if msg := validatePassword(req.Password); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	res, err := database.DB.Exec(
+		"INSERT INTO users (email, password_hash, role, school_id, phonenumber, fullname) VALUES (?, ?, ?, ?, ?, ?)",
+		req.Email, utils.HashPassword(req.Password), middleware.RoleFinance, middleware.CurrentUser(c).SchoolID,
+		req.PhoneNumber, req.FullName,
+	)
+	if database.IsDuplicate(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already registered"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create finance account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create finance account"})
+		return
+	}
+
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{"message": "Finance account created", "id": id})
+}
+
+func ListFeeStructures(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT f.id, f.school_id, f.department_id, d.name, f.year, f.semester, f.name, f.amount, f.due_date, f.created_at
+		FROM fee_structures f
+		JOIN departments d ON f.department_id = d.id
+		WHERE f.school_id = ?
+		ORDER BY f.semester DESC, d.name ASC, f.year ASC`,
+		middleware.CurrentUser(c).SchoolID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch fee structures"})
+		return
+	}
+	defer rows.Close()
+
+	fees := []models.FeeStructure{}
+	for rows.Next() {
+		var f models.FeeStructure
+		var due time.Time
+		if err := rows.Scan(&f.ID, &f.SchoolID, &f.DepartmentID, &f.Department, &f.Year, &f.Semester,
+			&f.Name, &f.Amount, &due, &f.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read fee structure data"})
+			return
+		}
+		f.DueDate = due.Format("2006-01-02")
+		fees = append(fees, f)
+	}
+
+	c.JSON(http.StatusOK, fees)
+}
+
+// CreateFeeStructure defines a fee and immediately invoices every matching student.
+func CreateFeeStructure(c *gin.Context) {
+	var input models.FeeStructure
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	input.Year = strings.TrimSpace(input.Year)
+	input.Semester = strings.TrimSpace(input.Semester)
+	if input.Name == "" || input.Year == "" || input.Semester == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name, year and semester are required"})
+		return
+	}
+	if input.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", input.DueDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "due_date must be formatted as YYYY-MM-DD"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	var exists bool
+	err := database.DB.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM departments WHERE id = ? AND school_id = ?)", input.DepartmentID, user.SchoolID,
+	).Scan(&exists)
+	if err != nil || !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Department not found"})
+		return
+	}
+
+	res, err := database.DB.Exec(`
+		INSERT INTO fee_structures (school_id, department_id, year, semester, name, amount, due_date, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.SchoolID, input.DepartmentID, input.Year, input.Semester, input.Name, roundMoney(input.Amount),
+		input.DueDate, user.ID,
+	)
+	if database.IsDuplicate(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This fee already exists for the department, year and semester"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create fee structure: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create fee structure"})
+		return
+	}
+	feeID, _ := res.LastInsertId()
+
+	inserted, err := generateInvoices(feeID, user.SchoolID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to generate invoices for fee %d: %v", feeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Fee created but invoice generation failed", "id": feeID})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Fee structure created", "id": feeID, "invoices_created": inserted})
+}
+
+// GenerateFeeInvoices invoices students who joined after the fee was created.
+// Students already invoiced for it are skipped.
+func GenerateFeeInvoices(c *gin.Context) {
+	feeID := c.Param("id")
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	var exists bool
+	err := database.DB.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM fee_structures WHERE id = ? AND school_id = ?)", feeID, schoolID,
+	).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch fee structure"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fee structure not found"})
+		return
+	}
+
+	inserted, err := generateInvoices(feeID, schoolID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to generate invoices for fee %s: %v", feeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invoices"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invoices generated", "invoices_created": inserted})
+}
+
+// generateInvoices bills every student of the fee's department and year who
+// doesn't have an invoice for it yet, in a single statement.
+func generateInvoices(feeID interface{}, schoolID int) (int64, error) {
+	res, err := database.DB.Exec(`
+		INSERT INTO invoices (school_id, student_id, fee_structure_id, description, amount, due_date)
+		SELECT s.school_id, s.id, f.id, CONCAT(f.name, ' - ', f.semester), f.amount, f.due_date
+		FROM fee_structures f
+		JOIN departments d ON d.id = f.department_id
+		JOIN students s ON s.school_id = f.school_id AND s.department = d.name AND s.year = f.year
+		WHERE f.id = ? AND f.school_id = ?
+		  AND NOT EXISTS (SELECT 1 FROM invoices i WHERE i.student_id = s.id AND i.fee_structure_id = f.id)`,
+		feeID, schoolID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func DeleteFeeStructure(c *gin.Context) {
+	feeID := c.Param("id")
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	var invoices int
+	err := database.DB.QueryRow(`
+		SELECT COUNT(i.id)
+		FROM fee_structures f
+		LEFT JOIN invoices i ON i.fee_structure_id = f.id
+		WHERE f.id = ? AND f.school_id = ?
+		GROUP BY f.id`,
+		feeID, schoolID,
+	).Scan(&invoices)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fee structure not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch fee structure"})
+		return
+	}
+	if invoices > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Fee structure has already been invoiced", "invoices": invoices})
+		return
+	}
+
+	if _, err := database.DB.Exec("DELETE FROM fee_structures WHERE id = ? AND school_id = ?", feeID, schoolID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete fee structure"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Fee structure deleted"})
+}
+
+func RecordPayment(c *gin.Context) {
+	var input models.Payment
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input.Method = strings.TrimSpace(input.Method)
+	input.Reference = strings.TrimSpace(input.Reference)
+	if input.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+	if input.Method == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "method is required (e.g. cash, bank, mobile-money)"})
+		return
+	}
+	if input.PaidOn == "" {
+		input.PaidOn = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", input.PaidOn); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "paid_on must be formatted as YYYY-MM-DD"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	if !middleware.InSchool(c, middleware.Tenant(c).Student(input.StudentID), "Student") {
+		return
+	}
+	if input.InvoiceID != nil {
+		var exists bool
+		err := database.DB.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM invoices WHERE id = ? AND student_id = ? AND school_id = ?)",
+			*input.InvoiceID, input.StudentID, user.SchoolID,
+		).Scan(&exists)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoice"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found for this student"})
+			return
+		}
+	}
+
+	res, err := database.DB.Exec(`
+		INSERT INTO payments (school_id, student_id, invoice_id, amount, method, reference, paid_on, recorded_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.SchoolID, input.StudentID, input.InvoiceID, roundMoney(input.Amount), input.Method, input.Reference,
+		input.PaidOn, user.ID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to record payment for student %d: %v", input.StudentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		return
+	}
+
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{"message": "Payment recorded", "id": id})
+}
+
+// GetStudentStatement lists a student's invoices and payments with a running
+// balance. Students see their own; finance staff and admins pass the id.
+func GetStudentStatement(c *gin.Context) {
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+
+	type Entry struct {
+		Date        string  `json:"date"`
+		Type        string  `json:"type"` // "invoice" or "payment"
+		ID          int     `json:"id"`
+		Description string  `json:"description"`
+		Debit       float64 `json:"debit"`
+		Credit      float64 `json:"credit"`
+		Balance     float64 `json:"balance"`
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT due_date, 'invoice', id, description, amount, created_at FROM invoices WHERE student_id = ?
+		UNION ALL
+		SELECT paid_on, 'payment', id, CONCAT(method, IF(reference = '', '', CONCAT(' ', reference))), amount, created_at
+		FROM payments WHERE student_id = ?
+		ORDER BY 1 ASC, 6 ASC`,
+		studentID, studentID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to build statement for student %d: %v", studentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch statement"})
+		return
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	var billed, paid, balance float64
+	for rows.Next() {
+		var e Entry
+		var date, createdAt time.Time
+		var amount float64
+		if err := rows.Scan(&date, &e.Type, &e.ID, &e.Description, &amount, &createdAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read statement"})
+			return
+		}
+		e.Date = date.Format("2006-01-02")
+		if e.Type == "invoice" {
+			e.Debit = amount
+			billed += amount
+			balance += amount
+		} else {
+			e.Credit = amount
+			paid += amount
+			balance -= amount
+		}
+		e.Balance = roundMoney(balance)
+		entries = append(entries, e)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"student_id":   studentID,
+		"total_billed": roundMoney(billed),
+		"total_paid":   roundMoney(paid),
+		"balance":      roundMoney(balance),
+		"entries":      entries,
+	})
+}
+
+// GetArrearsReport lists students who have paid less than what is already due.
+func GetArrearsReport(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.fullname, s.registrationNumber, s.department, s.year,
+		       COALESCE((SELECT SUM(amount) FROM invoices i WHERE i.student_id = s.id AND i.due_date < CURDATE()), 0),
+		       COALESCE((SELECT SUM(amount) FROM invoices i WHERE i.student_id = s.id), 0),
+		       COALESCE((SELECT SUM(amount) FROM payments p WHERE p.student_id = s.id), 0)
+		FROM students s
+		WHERE s.school_id = ?`,
+		middleware.CurrentUser(c).SchoolID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to build arrears report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build arrears report"})
+		return
+	}
+	defer rows.Close()
+
+	type Arrear struct {
+		StudentID          int     `json:"student_id"`
+		FullName           string  `json:"fullname"`
+		RegistrationNumber string  `json:"registrationNumber"`
+		Department         string  `json:"department"`
+		Year               string  `json:"year"`
+		Overdue            float64 `json:"overdue"`
+		Paid               float64 `json:"paid"`
+		Arrears            float64 `json:"arrears"`
+		Balance            float64 `json:"balance"`
+	}
+
+	report := []Arrear{}
+	var total float64
+	for rows.Next() {
+		var a Arrear
+		var billed float64
+		if err := rows.Scan(&a.StudentID, &a.FullName, &a.RegistrationNumber, &a.Department, &a.Year,
+			&a.Overdue, &billed, &a.Paid); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read arrears report"})
+			return
+		}
+		a.Arrears = roundMoney(a.Overdue - a.Paid)
+		if a.Arrears <= 0 {
+			continue
+		}
+		a.Balance = roundMoney(billed - a.Paid)
+		total += a.Arrears
+		report = append(report, a)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Arrears > report[j].Arrears })
+
+	c.JSON(http.StatusOK, gin.H{
+		"as_of":         time.Now().Format("2006-01-02"),
+		"students":      len(report),
+		"total_arrears": roundMoney(total),
+		"arrears":       report,
+	})
+}
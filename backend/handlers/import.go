@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/models"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportBytes         = 5 << 20
+	maxImportRows          = 2000
+	generatedPasswordChars = 12
+	maxAge                 = 100
+)
+
+// importColumns are the columns an import file may have, per role. Header
+// names are matched ignoring case, spaces, dashes and underscores.
+var importColumns = map[string][]string{
+	middleware.RoleStudent: {"fullname", "username", "email", "password", "registrationNumber", "department", "age", "year"},
+	middleware.RoleTeacher: {"fullname", "username", "email", "password", "subject", "employeeId", "department", "age", "year"},
+}
+
+// numberColumn is the per-school unique identifier besides the username.
+var numberColumn = map[string]string{
+	middleware.RoleStudent: "registrationNumber",
+	middleware.RoleTeacher: "employeeId",
+}
+
+var minAge = map[string]int{
+	middleware.RoleStudent: 10,
+	middleware.RoleTeacher: 18,
+}
+
+// ImportIssue is one problem found in an import file. Row is the line of the
+// CSV file, or the spreadsheet row, the problem is on.
+type ImportIssue struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// importRecord is a non-blank line of the file and where it was.
+type importRecord struct {
+	line  int
+	cells []string
+}
+
+// importRow is a validated row, ready to be saved.
+type importRow struct {
+	line     int
+	values   map[string]string
+	age      int
+	password string
+	// generated is set when the password was made up here and must be handed out
+	generated bool
+}
+
+// querier is what validation needs from either the DB or a transaction.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// ImportAccounts creates students or teachers from an uploaded CSV or XLSX
+// file. By default it only reports what it would do. With commit=true it
+// saves every row in one transaction, or nothing if any row is invalid. With
+// generate_passwords=true rows without a password get a random one, returned
+// once in the response (as a CSV file with format=csv) and never again.
+func ImportAccounts(c *gin.Context) {
+	role := c.Param("role")
+	if _, ok := importColumns[role]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be student or teacher"})
+		return
+	}
+	commit := c.Query("commit") == "true"
+	generate := c.Query("generate_passwords") == "true"
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Upload a CSV or XLSX file of at most %d MB as 'file'", maxImportBytes>>20)})
+		return
+	}
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+
+	records, err := readImportFile(header.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	columns, err := importHeader(role, records)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(records)-1 > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d rows can be imported at once", maxImportRows)})
+		return
+	}
+
+	schoolID := int64(middleware.CurrentUser(c).SchoolID)
+	rows, issues, err := validateImport(database.DB, role, schoolID, columns, records[1:], generate)
+	if err != nil {
+		log.Printf("[ERROR] Failed to validate %s import: %v", role, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate import"})
+		return
+	}
+
+	report := gin.H{
+		"role":    role,
+		"dry_run": !commit,
+		"rows":    len(records) - 1,
+		"valid":   len(rows),
+		"errors":  issues,
+	}
+	if !commit {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	if len(issues) > 0 {
+		report["message"] = "Nothing was imported. Fix the errors and upload the file again."
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	hashes := hashImportPasswords(rows)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := lockSchool(tx, schoolID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import"})
+		return
+	}
+	// accounts may have been created since the check above
+	if _, issues, err = validateImport(tx, role, schoolID, columns, records[1:], generate); err != nil || len(issues) > 0 {
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import"})
+			return
+		}
+		report["errors"] = issues
+		report["message"] = "Nothing was imported. Some accounts were created while the file was being checked."
+		c.JSON(http.StatusConflict, report)
+		return
+	}
+
+	now := time.Now()
+	for i, r := range rows {
+		v := r.values
+		if role == middleware.RoleStudent {
+			_, err = SaveStudentToDB(tx, models.Student{
+				FullName: v["fullname"], Username: v["username"], Email: v["email"], Password: hashes[i],
+				RegistrationNumber: v["registrationNumber"], Department: v["department"], Age: r.age,
+				Year: v["year"], CreatedAt: now,
+			}, schoolID, accountActive)
+		} else {
+			_, err = SaveTeacherToDB(tx, models.Teacher{
+				FullName: v["fullname"], Username: v["username"], Email: v["email"], Password: hashes[i],
+				Subject: v["subject"], EmployeeId: v["employeeId"], Department: v["department"], Age: r.age,
+				Year: v["year"], CreatedAt: now,
+			}, schoolID, accountActive)
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to import row %d: %v", r.line, err)
+			report["errors"] = []ImportIssue{{Row: r.line, Message: "could not be saved"}}
+			report["message"] = "Nothing was imported."
+			c.JSON(http.StatusInternalServerError, report)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	log.Printf("[INFO] %s %d imported %d %ss", user.Role, user.ID, len(rows), role)
+
+	type Credential struct {
+		Row      int    `json:"row"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	credentials := []Credential{}
+	for _, r := range rows {
+		if r.generated {
+			credentials = append(credentials, Credential{r.line, r.values["username"], r.password})
+		}
+	}
+
+	// the generated passwords exist nowhere else, so nothing may cache them
+	c.Header("Cache-Control", "no-store")
+	if c.Query("format") == "csv" {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"row", "username", "password"})
+		for _, cr := range credentials {
+			w.Write([]string{strconv.Itoa(cr.Row), cr.Username, cr.Password})
+		}
+		w.Flush()
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-credentials.csv"`, role))
+		c.Data(http.StatusCreated, "text/csv; charset=utf-8", buf.Bytes())
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     fmt.Sprintf("Imported %d %ss", len(rows), role),
+		"imported":    len(rows),
+		"credentials": credentials,
+	})
+}
+
+// readImportFile turns an upload into records, the first being the header.
+// Blank lines are dropped, but each record keeps its place in the file so
+// problems are reported against the line the user sees.
+func readImportFile(name string, data []byte) ([]importRecord, error) {
+	var records []importRecord
+	if strings.HasSuffix(strings.ToLower(name), ".xlsx") || bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		rows, err := utils.ReadXLSX(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			records = append(records, importRecord{line: row.Number, cells: row.Cells})
+		}
+	} else {
+		r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid CSV: %w", err)
+			}
+			line, _ := r.FieldPos(0)
+			records = append(records, importRecord{line: line, cells: rec})
+		}
+	}
+
+	kept := records[:0]
+	for _, rec := range records {
+		if strings.TrimSpace(strings.Join(rec.cells, "")) != "" {
+			kept = append(kept, rec)
+		}
+	}
+	if len(kept) < 2 {
+		return nil, fmt.Errorf("the file needs a header row and at least one account")
+	}
+	return kept, nil
+}
+
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.TrimSpace(name)))
+}
+
+// importHeader maps each column of the file to a field name.
+func importHeader(role string, records []importRecord) ([]string, error) {
+	known := map[string]string{}
+	for _, f := range importColumns[role] {
+		known[normalizeColumn(f)] = f
+	}
+
+	columns := make([]string, len(records[0].cells))
+	seen := map[string]bool{}
+	for i, h := range records[0].cells {
+		field, ok := known[normalizeColumn(h)]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q; columns are %s", h, strings.Join(importColumns[role], ", "))
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("column %q appears twice", h)
+		}
+		seen[field] = true
+		columns[i] = field
+	}
+	for _, f := range []string{"fullname", "username", "department"} {
+		if !seen[f] {
+			return nil, fmt.Errorf("missing required column %q", f)
+		}
+	}
+	return columns, nil
+}
+
+// validateImport checks every row against the school's departments, its
+// existing accounts and the other rows, and collects all the problems.
+func validateImport(q querier, role string, schoolID int64, columns []string, records []importRecord, generate bool) ([]importRow, []ImportIssue, error) {
+	departments, err := lowerSet(q, "SELECT name FROM departments WHERE school_id = ?", schoolID)
+	if err != nil {
+		return nil, nil, err
+	}
+	table, number := role+"s", numberColumn[role]
+	usernames, err := lowerSet(q, "SELECT username FROM "+table+" WHERE school_id = ?", schoolID)
+	if err != nil {
+		return nil, nil, err
+	}
+	numbers, err := lowerSet(q, "SELECT "+number+" FROM "+table+" WHERE school_id = ? AND "+number+" <> ''", schoolID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := []importRow{}
+	issues := []ImportIssue{}
+	fileUsernames := map[string]int{}
+	fileNumbers := map[string]int{}
+	for _, rec := range records {
+		line := rec.line
+		r := importRow{line: line, values: map[string]string{}}
+		for j, field := range columns {
+			if j < len(rec.cells) {
+				r.values[field] = strings.TrimSpace(rec.cells[j])
+			}
+		}
+		v := r.values
+		bad := func(field, msg string) {
+			issues = append(issues, ImportIssue{Row: line, Field: field, Message: msg})
+		}
+		before := len(issues)
+
+		if v["fullname"] == "" {
+			bad("fullname", "is required")
+		}
+		switch username := strings.ToLower(v["username"]); {
+		case username == "":
+			bad("username", "is required")
+		case usernames[username] != "":
+			bad("username", "is already in use in the school")
+		case fileUsernames[username] != 0:
+			bad("username", fmt.Sprintf("duplicates row %d", fileUsernames[username]))
+		default:
+			fileUsernames[username] = line
+		}
+		if n := strings.ToLower(v[number]); n != "" {
+			switch {
+			case numbers[n] != "":
+				bad(number, "is already in use in the school")
+			case fileNumbers[n] != 0:
+				bad(number, fmt.Sprintf("duplicates row %d", fileNumbers[n]))
+			default:
+				fileNumbers[n] = line
+			}
+		}
+		if dept, ok := departments[strings.ToLower(v["department"])]; ok {
+			v["department"] = dept // use the school's spelling
+		} else if v["department"] == "" {
+			bad("department", "is required")
+		} else {
+			bad("department", fmt.Sprintf("%q is not a department of the school", v["department"]))
+		}
+		if a := v["age"]; a != "" {
+			age, err := strconv.Atoi(a)
+			if err != nil || age < minAge[role] || age > maxAge {
+				bad("age", fmt.Sprintf("must be a whole number from %d to %d", minAge[role], maxAge))
+			}
+			r.age = age
+		}
+		if e := v["email"]; e != "" && !strings.Contains(e, "@") {
+			bad("email", "is not a valid email address")
+		}
+		switch {
+		case v["password"] != "":
+			if msg := validatePassword(v["password"]); msg != "" {
+				bad("password", msg)
+			}
+			r.password = v["password"]
+		case generate:
+			r.password = utils.RandomPassword(generatedPasswordChars)
+			r.generated = true
+		default:
+			bad("password", "is required unless passwords are generated")
+		}
+
+		if len(issues) == before {
+			rows = append(rows, r)
+		}
+	}
+	return rows, issues, nil
+}
+
+// lowerSet loads one column into a set keyed by its lowercased value, which
+// is how MySQL's default collation compares them.
+func lowerSet(q querier, query string, args ...interface{}) (map[string]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	set := map[string]string{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		set[strings.ToLower(v)] = v
+	}
+	return set, rows.Err()
+}
+
+// hashImportPasswords bcrypts the rows' passwords on every core; one at a time
+// would take minutes for a large file.
+func hashImportPasswords(rows []importRow) []string {
+	hashes := make([]string, len(rows))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				hashes[i] = utils.HashPassword(rows[i].password)
+			}
+		}()
+	}
+	for i := range rows {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return hashes
+}
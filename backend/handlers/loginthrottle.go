@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	throttleAccount = "account"
+	throttleIP      = "ip"
+
+	// failures are forgotten once none has happened for this long; it must
+	// outlast lockoutMax so purging a row never lifts a lockout
+	throttleWindow = 24 * time.Hour
+	lockoutBase    = 30 * time.Second
+	lockoutMax     = time.Hour
+	// at most this many expired rows are purged per failed sign-in
+	throttlePurgeBatch = 100
+
+	invalidLogin = "Invalid username or password"
+)
+
+// freeAttempts is how many failures are allowed before lockouts start. An IP
+// gets more because a whole school can sit behind one NAT.
+var freeAttempts = map[string]int{
+	throttleAccount: 5,
+	throttleIP:      20,
+}
+
+// dummyHash is checked against when the account doesn't exist, so a missing
+// username takes as long to reject as a wrong password.
+var dummyHash = utils.HashPassword("not-a-real-password")
+
+// loginKey names an account for throttling. Student and teacher usernames are
+// per school; staff sign in by email.
+func loginKey(role, slug, name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch role {
+	case middleware.RoleStudent, middleware.RoleTeacher:
+		return role + ":" + strings.ToLower(slug) + ":" + name
+	}
+	return "user:" + name
+}
+
+// lockoutFor gives the lockout after the nth consecutive failure: nothing
+// during the free attempts, then doubling from lockoutBase up to lockoutMax.
+func lockoutFor(scope string, failures int) time.Duration {
+	over := failures - freeAttempts[scope]
+	if over < 0 {
+		return 0
+	}
+	d := time.Duration(float64(lockoutBase) * math.Pow(2, float64(over)))
+	if d > lockoutMax || d <= 0 {
+		d = lockoutMax
+	}
+	return d
+}
+
+// allowLogin refuses the attempt with 429 while the account or the client IP
+// is locked out. The message doesn't say which.
+func allowLogin(c *gin.Context, accountKey string) bool {
+	var wait int
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(MAX(TIMESTAMPDIFF(SECOND, NOW(), locked_until)), 0)
+		FROM login_throttles
+		WHERE ((scope = ? AND throttle_key = ?) OR (scope = ? AND throttle_key = ?)) AND locked_until > NOW()`,
+		throttleAccount, accountKey, throttleIP, c.ClientIP(),
+	).Scan(&wait)
+	if err != nil {
+		// don't lock everyone out because the throttle table is unavailable
+		log.Printf("[ERROR] Failed to check login throttle: %v", err)
+		return true
+	}
+	if wait <= 0 {
+		return true
+	}
+	c.Header("Retry-After", fmt.Sprint(wait+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed sign-in attempts. Try again later.",
+		"retry_after": wait + 1,
+	})
+	return false
+}
+
+// rejectLogin counts a failed attempt against the account and the client IP
+// and answers with the same message whatever went wrong.
+func rejectLogin(c *gin.Context, accountKey string, schoolID int) {
+	var school interface{}
+	if schoolID > 0 {
+		school = schoolID
+	}
+	for _, t := range []struct{ scope, key string }{{throttleAccount, accountKey}, {throttleIP, c.ClientIP()}} {
+		if err := recordLoginFailure(t.scope, t.key, school); err != nil {
+			log.Printf("[ERROR] Failed to record failed login for %s %s: %v", t.scope, t.key, err)
+		}
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": invalidLogin})
+}
+
+func recordLoginFailure(scope, key string, school interface{}) error {
+	// rows nobody has failed on within the window are dead weight; clearing a
+	// batch on each write keeps the table to recent failures
+	if _, err := database.DB.Exec(
+		"DELETE FROM login_throttles WHERE last_failure_at < DATE_SUB(NOW(), INTERVAL ? SECOND) LIMIT ?",
+		int(throttleWindow.Seconds()), throttlePurgeBatch,
+	); err != nil {
+		log.Printf("[ERROR] Failed to purge expired login throttles: %v", err)
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO login_throttles (scope, throttle_key, school_id, failures, last_failure_at)
+		VALUES (?, ?, ?, 1, NOW())
+		ON DUPLICATE KEY UPDATE
+			failures = IF(last_failure_at < DATE_SUB(NOW(), INTERVAL ? SECOND), 1, failures + 1),
+			last_failure_at = NOW(),
+			school_id = COALESCE(VALUES(school_id), school_id)`,
+		scope, key, school, int(throttleWindow.Seconds()),
+	)
+	if err != nil {
+		return err
+	}
+
+	var failures int
+	if err := database.DB.QueryRow(
+		"SELECT failures FROM login_throttles WHERE scope = ? AND throttle_key = ?", scope, key,
+	).Scan(&failures); err != nil {
+		return err
+	}
+	if lock := lockoutFor(scope, failures); lock > 0 {
+		_, err = database.DB.Exec(
+			"UPDATE login_throttles SET locked_until = DATE_ADD(NOW(), INTERVAL ? SECOND) WHERE scope = ? AND throttle_key = ?",
+			int(lock.Seconds()), scope, key,
+		)
+	}
+	return err
+}
+
+// acceptLogin clears the account's failures. The IP's are left to expire so
+// one valid account can't be used to reset the counter for a whole network.
+func acceptLogin(accountKey string) {
+	if _, err := database.DB.Exec(
+		"DELETE FROM login_throttles WHERE scope = ? AND throttle_key = ?", throttleAccount, accountKey,
+	); err != nil {
+		log.Printf("[ERROR] Failed to clear login throttle for %s: %v", accountKey, err)
+	}
+}
+
+// ListLockedAccounts shows the school's accounts that are currently locked out.
+func ListLockedAccounts(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT throttle_key, failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE scope = ? AND school_id = ? AND locked_until > NOW()
+		ORDER BY locked_until DESC`,
+		throttleAccount, middleware.CurrentUser(c).SchoolID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locked accounts"})
+		return
+	}
+	defer rows.Close()
+
+	type LockedAccount struct {
+		Account       string    `json:"account"`
+		Failures      int       `json:"failures"`
+		LastFailureAt time.Time `json:"last_failure_at"`
+		LockedUntil   time.Time `json:"locked_until"`
+	}
+
+	locked := []LockedAccount{}
+	for rows.Next() {
+		var l LockedAccount
+		var until sql.NullTime
+		if err := rows.Scan(&l.Account, &l.Failures, &l.LastFailureAt, &until); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read locked accounts"})
+			return
+		}
+		l.LockedUntil = until.Time
+		locked = append(locked, l)
+	}
+
+	c.JSON(http.StatusOK, locked)
+}
+
+// UnlockAccount clears the failed attempts of one of the school's accounts.
+// Students and teachers are named by username, staff by email. Only the
+// school admin can unlock staff, so a registrar can't reopen an admin or
+// finance account to guessing.
+func UnlockAccount(c *gin.Context) {
+	var input struct {
+		Role     string `json:"role"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	name := input.Username
+	if input.Role != middleware.RoleStudent && input.Role != middleware.RoleTeacher {
+		if user.Role != middleware.RoleMainAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the school admin can unlock staff accounts"})
+			return
+		}
+		name = input.Email
+	}
+	key := loginKey(input.Role, user.DBSlug, name)
+
+	res, err := database.DB.Exec(
+		"DELETE FROM login_throttles WHERE scope = ? AND throttle_key = ? AND school_id = ?",
+		throttleAccount, key, user.SchoolID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to unlock %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No failed sign-ins recorded for this account"})
+		return
+	}
+
+	log.Printf("[INFO] %s %d unlocked %s", user.Role, user.ID, key)
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CatDraft     = "draft"
+	CatPublished = "published"
+)
+
+// CatStats summarises the marks recorded for one CAT.
+type CatStats struct {
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+}
+
+type MarkEntry struct {
+	StudentID int     `json:"student_id"`
+	Score     float64 `json:"score"`
+}
+
+type MarksInput struct {
+	MaxScore *float64    `json:"max_score"`
+	Marks    []MarkEntry `json:"marks"`
+}
+
+// computeStats returns the class statistics for a set of scores.
+func computeStats(scores []float64) CatStats {
+	if len(scores) == 0 {
+		return CatStats{}
+	}
+	sorted := append([]float64(nil), scores...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, s := range sorted {
+		sum += s
+	}
+	n := len(sorted)
+	median := sorted[n/2]
+	if n%2 == 0 {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return CatStats{Count: n, Mean: sum / float64(n), Median: median}
+}
+
+// rankOf gives competition ranking: tied scores share a rank.
+func rankOf(score float64, scores []float64) int {
+	rank := 1
+	for _, s := range scores {
+		if s > score {
+			rank++
+		}
+	}
+	return rank
+}
+
+// GetCatMarks returns the roster of students enrolled in the CAT's course with
+// any marks recorded so far.
+func GetCatMarks(c *gin.Context) {
+	catID := c.Param("id")
+	if !ownsCat(c, catID) {
+		return
+	}
+
+	var courseID, termID int
+	var maxScore float64
+	var status string
+	err := database.DB.QueryRow("SELECT course_id, term_id, max_score, status FROM cats WHERE id = ?", catID).
+		Scan(&courseID, &termID, &maxScore, &status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch CAT"})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.fullname, s.registrationNumber, cm.score
+		FROM student_courses sc
+		JOIN students s ON sc.student_id = s.id
+		LEFT JOIN cat_marks cm ON cm.student_id = s.id AND cm.cat_id = ?
+		WHERE sc.course_id = ? AND sc.term_id = ?
+		ORDER BY s.fullname ASC`,
+		catID, courseID, termID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch marks for CAT %s: %v", catID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch marks"})
+		return
+	}
+	defer rows.Close()
+
+	type StudentMark struct {
+		StudentID          int      `json:"student_id"`
+		FullName           string   `json:"fullname"`
+		RegistrationNumber string   `json:"registrationNumber"`
+		Score              *float64 `json:"score"`
+	}
+
+	marks := []StudentMark{}
+	var scores []float64
+	for rows.Next() {
+		var m StudentMark
+		var score sql.NullFloat64
+		if err := rows.Scan(&m.StudentID, &m.FullName, &m.RegistrationNumber, &score); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read marks"})
+			return
+		}
+		if score.Valid {
+			m.Score = &score.Float64
+			scores = append(scores, score.Float64)
+		}
+		marks = append(marks, m)
+	}
+
+	id, _ := strconv.Atoi(catID)
+	c.JSON(http.StatusOK, gin.H{
+		"cat_id":    id,
+		"max_score": maxScore,
+		"status":    status,
+		"stats":     computeStats(scores),
+		"marks":     marks,
+	})
+}
+
+// SaveCatMarks records or overwrites marks in bulk. Every student must be
+// enrolled in the CAT's course; the whole batch is rejected otherwise.
+func SaveCatMarks(c *gin.Context) {
+	catID := c.Param("id")
+	var input MarksInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if !ownsCat(c, catID) {
+		return
+	}
+
+	var courseID, termID int
+	var maxScore float64
+	err := database.DB.QueryRow("SELECT course_id, term_id, max_score FROM cats WHERE id = ?", catID).Scan(&courseID, &termID, &maxScore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch CAT"})
+		return
+	}
+	if input.MaxScore != nil {
+		if *input.MaxScore <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_score must be positive"})
+			return
+		}
+		maxScore = *input.MaxScore
+	}
+
+	enrolled := make(map[int]bool)
+	rows, err := database.DB.Query("SELECT student_id FROM student_courses WHERE course_id = ? AND term_id = ?", courseID, termID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollments"})
+		return
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			enrolled[id] = true
+		}
+	}
+	rows.Close()
+
+	var invalid []gin.H
+	for _, m := range input.Marks {
+		switch {
+		case !enrolled[m.StudentID]:
+			invalid = append(invalid, gin.H{"student_id": m.StudentID, "error": "Student is not enrolled in this course"})
+		case m.Score < 0 || m.Score > maxScore:
+			invalid = append(invalid, gin.H{"student_id": m.StudentID, "error": "Score must be between 0 and max_score"})
+		}
+	}
+	if len(invalid) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Some marks were rejected", "invalid": invalid})
+		return
+	}
+
+	// a lower max score must still fit the recorded marks this request
+	// doesn't replace
+	if input.MaxScore != nil {
+		replaced := make(map[int]bool, len(input.Marks))
+		for _, m := range input.Marks {
+			replaced[m.StudentID] = true
+		}
+		rows, err := database.DB.Query("SELECT student_id, score FROM cat_marks WHERE cat_id = ? AND score > ?", catID, maxScore)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check marks"})
+			return
+		}
+		var over []int
+		for rows.Next() {
+			var studentID int
+			var score float64
+			if err := rows.Scan(&studentID, &score); err == nil && !replaced[studentID] {
+				over = append(over, studentID)
+			}
+		}
+		rows.Close()
+		if len(over) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Existing marks exceed the new max_score", "student_ids": over})
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save marks"})
+		return
+	}
+	defer tx.Rollback()
+
+	if input.MaxScore != nil {
+		if _, err := tx.Exec("UPDATE cats SET max_score = ? WHERE id = ?", maxScore, catID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update max score"})
+			return
+		}
+	}
+
+	recordedBy := middleware.CurrentUser(c).ID
+	for _, m := range input.Marks {
+		_, err := tx.Exec(`
+			INSERT INTO cat_marks (cat_id, student_id, score, recorded_by)
+			VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE score = VALUES(score), recorded_by = VALUES(recorded_by)`,
+			catID, m.StudentID, m.Score, recordedBy,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to save mark for student %d on CAT %s: %v", m.StudentID, catID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save marks"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save marks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Marks saved", "saved": len(input.Marks)})
+}
+
+// PublishCatMarks makes a CAT's marks visible to students.
+func PublishCatMarks(c *gin.Context) {
+	setCatStatus(c, CatPublished)
+}
+
+// UnpublishCatMarks hides a CAT's marks again, e.g. to correct them.
+func UnpublishCatMarks(c *gin.Context) {
+	setCatStatus(c, CatDraft)
+}
+
+func setCatStatus(c *gin.Context, status string) {
+	catID := c.Param("id")
+	if !ownsCat(c, catID) {
+		return
+	}
+
+	var publishedAt interface{}
+	if status == CatPublished {
+		publishedAt = time.Now()
+	}
+	_, err := database.DB.Exec("UPDATE cats SET status = ?, published_at = ? WHERE id = ?", status, publishedAt, catID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to set CAT %s to %s: %v", catID, status, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update CAT"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "CAT marks " + status, "status": status})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"school-backend/config"
+	"school-backend/database"
+	"school-backend/mail"
+	"school-backend/middleware"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	resetTokenTTL     = time.Hour
+	minPasswordLength = 8
+)
+
+// accountTable says where the password of an account with the given role is
+// stored. Every staff role lives in users.
+func accountTable(role string) (kind, table, column string) {
+	switch role {
+	case middleware.RoleStudent:
+		return "student", "students", "password"
+	case middleware.RoleTeacher:
+		return "teacher", "teachers", "password"
+	}
+	return "user", "users", "password_hash"
+}
+
+func accountTableByKind(kind string) (table, column string, ok bool) {
+	switch kind {
+	case "student":
+		return "students", "password", true
+	case "teacher":
+		return "teachers", "password", true
+	case "user":
+		return "users", "password_hash", true
+	}
+	return "", "", false
+}
+
+func validatePassword(password string) string {
+	if len(password) < minPasswordLength {
+		return fmt.Sprintf("Password must be at least %d characters", minPasswordLength)
+	}
+	return ""
+}
+
+// ChangePassword lets a signed-in user replace their password after
+// confirming the current one.
+func ChangePassword(c *gin.Context) {
+	var input struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if msg := validatePassword(input.NewPassword); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	kind, table, column := accountTable(user.Role)
+
+	var hash string
+	err := database.DB.QueryRow(
+		"SELECT "+column+" FROM "+table+" WHERE id = ? AND school_id = ?", user.ID, user.SchoolID,
+	).Scan(&hash)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load %s %d for password change: %v", kind, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	if !utils.CheckPassword(input.CurrentPassword, hash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if err := setPassword(kind, user.ID, input.NewPassword, user.SessionID); err != nil {
+		log.Printf("[ERROR] Failed to change password for %s %d: %v", kind, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+// setPassword stores a new password, voids any reset links still out and
+// signs out every session except keep (0 signs out all of them).
+func setPassword(kind string, id int, password string, keep int) error {
+	table, column, ok := accountTableByKind(kind)
+	if !ok {
+		return fmt.Errorf("unknown account kind %q", kind)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE "+table+" SET "+column+" = ? WHERE id = ?", utils.HashPassword(password), id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE password_resets SET used_at = NOW() WHERE owner_kind = ? AND owner_id = ? AND used_at IS NULL", kind, id,
+	); err != nil {
+		return err
+	}
+	if _, err := revokeSessions(tx, kind, id, keep); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ForgotPassword emails a reset link. Students and teachers are looked up by
+// school slug and username, staff by email. The answer is the same whether or
+// not the account exists, so it can't be used to probe for accounts.
+func ForgotPassword(c *gin.Context) {
+	var input struct {
+		Role     string `json:"role"`
+		Slug     string `json:"slug"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	kind, table, _ := accountTable(input.Role)
+	var (
+		id    int
+		email string
+		err   error
+	)
+	if kind == "user" {
+		err = database.DB.QueryRow(
+			"SELECT id, email FROM users WHERE email = ?", strings.TrimSpace(input.Email),
+		).Scan(&id, &email)
+	} else {
+		err = database.DB.QueryRow(
+			"SELECT t.id, t.email FROM "+table+" t JOIN schools sch ON t.school_id = sch.id WHERE t.username = ? AND sch.slug = ?",
+			input.Username, input.Slug,
+		).Scan(&id, &email)
+	}
+
+	const sent = "If the account exists and has an email address, a reset link has been sent"
+	if err == sql.ErrNoRows || (err == nil && email == "") {
+		c.JSON(http.StatusOK, gin.H{"message": sent})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to look up %s for password reset: %v", kind, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start password reset"})
+		return
+	}
+
+	token := utils.RandomToken(32)
+	_, err = database.DB.Exec(`
+		INSERT INTO password_resets (owner_kind, owner_id, token_hash, expires_at)
+		VALUES (?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))`,
+		kind, id, utils.HashToken(token), int(resetTokenTTL.Seconds()),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to store reset token for %s %d: %v", kind, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start password reset"})
+		return
+	}
+
+	link := config.App.FrontendURL + "/reset-password?token=" + url.QueryEscape(token)
+	err = mail.Send(mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: "Someone asked to reset the password for your account.\n\n" +
+			"Open this link within " + resetTokenTTL.String() + " to choose a new password:\n" + link + "\n\n" +
+			"If it wasn't you, ignore this email and your password will stay the same.\n",
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to send reset email to %s %d: %v", kind, id, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": sent})
+}
+
+// ResetPassword sets a new password from an emailed token. Tokens work once
+// and expire after resetTokenTTL.
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if msg := validatePassword(input.Password); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// claim the token first so two requests racing with it can't both succeed
+	hash := utils.HashToken(input.Token)
+	res, err := database.DB.Exec(
+		"UPDATE password_resets SET used_at = NOW() WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW()", hash,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	if claimed, _ := res.RowsAffected(); claimed != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This reset link is invalid or has expired"})
+		return
+	}
+
+	var kind string
+	var ownerID int
+	err = database.DB.QueryRow("SELECT owner_kind, owner_id FROM password_resets WHERE token_hash = ?", hash).
+		Scan(&kind, &ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := setPassword(kind, ownerID, input.Password, 0); err != nil {
+		log.Printf("[ERROR] Failed to reset password for %s %d: %v", kind, ownerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
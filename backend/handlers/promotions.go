@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Outcomes of the end-of-year run for one student.
+const (
+	promotionPromoted  = "promoted"
+	promotionRepeated  = "repeated"
+	promotionGraduated = "graduated"
+)
+
+var promotionOutcomes = map[string]bool{
+	promotionPromoted:  true,
+	promotionRepeated:  true,
+	promotionGraduated: true,
+}
+
+// PromotionRules decide who moves up at the end of the year. A course counts
+// towards min_credits when the student scored at least pass_mark percent of
+// its published CAT marks; students in final_year who meet the rule graduate.
+type PromotionRules struct {
+	MinCredits int     `json:"min_credits"`
+	PassMark   float64 `json:"pass_mark"`
+	FinalYear  int     `json:"final_year"`
+}
+
+func promotionRules(q querier, schoolID int) (PromotionRules, error) {
+	var r PromotionRules
+	err := q.QueryRow(
+		"SELECT promotion_min_credits, promotion_pass_mark, promotion_final_year FROM schools WHERE id = ?", schoolID,
+	).Scan(&r.MinCredits, &r.PassMark, &r.FinalYear)
+	return r, err
+}
+
+// nextYear moves the number in a year label up by one, keeping the rest of
+// the label ("Year 2" becomes "Year 3").
+func nextYear(year string) string {
+	start := strings.IndexAny(year, "0123456789")
+	if start < 0 {
+		return year
+	}
+	end := start
+	for end < len(year) && year[end] >= '0' && year[end] <= '9' {
+		end++
+	}
+	return year[:start] + strconv.Itoa(studentYear(year)+1) + year[end:]
+}
+
+// GetPromotionRules returns the school's end-of-year rules.
+func GetPromotionRules(c *gin.Context) {
+	rules, err := promotionRules(database.DB, middleware.CurrentUser(c).SchoolID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promotion rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// UpdatePromotionRules replaces the school's end-of-year rules. Years already
+// processed keep the outcomes they were given.
+func UpdatePromotionRules(c *gin.Context) {
+	var input PromotionRules
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if input.MinCredits < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_credits can't be negative"})
+		return
+	}
+	if input.PassMark <= 0 || input.PassMark > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pass_mark must be a percentage above 0 and at most 100"})
+		return
+	}
+	if input.FinalYear < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "final_year must be at least 1"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	if _, err := database.DB.Exec(
+		"UPDATE schools SET promotion_min_credits = ?, promotion_pass_mark = ?, promotion_final_year = ? WHERE id = ?",
+		input.MinCredits, input.PassMark, input.FinalYear, user.SchoolID,
+	); err != nil {
+		log.Printf("[ERROR] Failed to update promotion rules for school %d: %v", user.SchoolID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update promotion rules"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion rules updated", "rules": input})
+}
+
+// CourseResult is how a student did in one course during the year.
+type CourseResult struct {
+	CourseID    int      `json:"course_id"`
+	Code        string   `json:"code"`
+	TermID      int      `json:"term_id"`
+	CreditUnits int      `json:"credit_units"`
+	Percentage  *float64 `json:"percentage"` // nil when no CAT was published
+	Passed      bool     `json:"passed"`
+}
+
+// RunPromotions applies the promotion rules to every active student for an
+// academic year, the current one unless academic_year_id is given. Without
+// commit=true it only previews the outcomes. Students who meet the rules move
+// up a year, or graduate from the final year; the rest repeat. Each student is
+// processed once per year, so a run can be repeated to pick up students
+// skipped the first time. Graduates become alumni: they can still sign in to
+// see their records but can no longer enroll, and their waitlist places and
+// pending requests are withdrawn.
+func RunPromotions(c *gin.Context) {
+	var input struct {
+		AcademicYearID *int   `json:"academic_year_id"`
+		Department     string `json:"department"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input.Department = strings.TrimSpace(input.Department)
+	user := middleware.CurrentUser(c)
+	commit := c.Query("commit") == "true"
+
+	var yearID int
+	if input.AcademicYearID != nil {
+		yearID = *input.AcademicYearID
+		if !middleware.InSchool(c, middleware.Tenant(c).AcademicYear(yearID), "Academic year") {
+			return
+		}
+	} else {
+		err := database.DB.QueryRow(
+			"SELECT id FROM academic_years WHERE school_id = ? AND is_current = 1", user.SchoolID,
+		).Scan(&yearID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No academic year is current; pass academic_year_id"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch academic year"})
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process promotions"})
+		return
+	}
+	defer tx.Rollback()
+
+	// one run at a time per school
+	if err := lockSchool(tx, int64(user.SchoolID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process promotions"})
+		return
+	}
+	rules, err := promotionRules(tx, user.SchoolID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promotion rules"})
+		return
+	}
+
+	rows, err := tx.Query(`
+		SELECT s.id, s.fullname, s.registrationNumber, s.department, s.year
+		FROM students s
+		WHERE s.school_id = ? AND s.status = ? AND (? = '' OR s.department = ?)
+		  AND NOT EXISTS (SELECT 1 FROM promotion_records p WHERE p.student_id = s.id AND p.academic_year_id = ?)
+		ORDER BY s.department ASC, s.year ASC, s.fullname ASC`,
+		user.SchoolID, accountActive, input.Department, input.Department, yearID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch students"})
+		return
+	}
+
+	type Student struct {
+		ID                 int            `json:"id"`
+		FullName           string         `json:"fullname"`
+		RegistrationNumber string         `json:"registrationNumber"`
+		Department         string         `json:"department"`
+		FromYear           string         `json:"from_year"`
+		ToYear             string         `json:"to_year"`
+		Outcome            string         `json:"outcome"`
+		CreditsPassed      int            `json:"credits_passed"`
+		Courses            []CourseResult `json:"courses"`
+	}
+	students := []*Student{}
+	byID := map[int]*Student{}
+	// a course passed in more than one term only counts once
+	credited := map[[2]int]bool{}
+	for rows.Next() {
+		s := &Student{Courses: []CourseResult{}}
+		if err := rows.Scan(&s.ID, &s.FullName, &s.RegistrationNumber, &s.Department, &s.FromYear); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read students"})
+			return
+		}
+		students = append(students, s)
+		byID[s.ID] = s
+	}
+	rows.Close()
+
+	// every course taken in one of the year's terms, with the student's share
+	// of the marks on offer in its published CATs; a CAT without a mark
+	// counts as zero
+	results, err := tx.Query(`
+		SELECT sc.student_id, c.id, c.code, sc.term_id, c.credit_units,
+		       SUM(COALESCE(cm.score, 0)), SUM(cats.max_score)
+		FROM student_courses sc
+		JOIN semesters sem ON sem.id = sc.term_id
+		JOIN courses c ON c.id = sc.course_id
+		LEFT JOIN cats ON cats.course_id = sc.course_id AND cats.term_id = sc.term_id AND cats.status = ?
+		LEFT JOIN cat_marks cm ON cm.cat_id = cats.id AND cm.student_id = sc.student_id
+		WHERE sem.academic_year_id = ? AND c.school_id = ?
+		GROUP BY sc.student_id, c.id, c.code, sc.term_id, c.credit_units
+		ORDER BY c.code ASC`,
+		CatPublished, yearID, user.SchoolID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch course results for academic year %d: %v", yearID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course results"})
+		return
+	}
+	for results.Next() {
+		var studentID int
+		var r CourseResult
+		var scored, available sql.NullFloat64
+		if err := results.Scan(&studentID, &r.CourseID, &r.Code, &r.TermID, &r.CreditUnits, &scored, &available); err != nil {
+			results.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read course results"})
+			return
+		}
+		s := byID[studentID]
+		if s == nil {
+			continue
+		}
+		if available.Valid && available.Float64 > 0 {
+			pct := math.Round(scored.Float64/available.Float64*10000) / 100
+			r.Percentage = &pct
+			r.Passed = pct >= rules.PassMark
+		}
+		if r.Passed && !credited[[2]int{studentID, r.CourseID}] {
+			credited[[2]int{studentID, r.CourseID}] = true
+			s.CreditsPassed += r.CreditUnits
+		}
+		s.Courses = append(s.Courses, r)
+	}
+	results.Close()
+
+	counts := map[string]int{promotionPromoted: 0, promotionRepeated: 0, promotionGraduated: 0}
+	// students whose year has no number can't be moved up
+	skipped := []gin.H{}
+	processed := []*Student{}
+	for _, s := range students {
+		year := studentYear(s.FromYear)
+		if year == 0 {
+			skipped = append(skipped, gin.H{"id": s.ID, "fullname": s.FullName, "year": s.FromYear, "reason": "Year has no number"})
+			continue
+		}
+
+		s.ToYear = s.FromYear
+		switch {
+		case s.CreditsPassed < rules.MinCredits:
+			s.Outcome = promotionRepeated
+		case year >= rules.FinalYear:
+			s.Outcome = promotionGraduated
+		default:
+			s.Outcome = promotionPromoted
+			s.ToYear = nextYear(s.FromYear)
+		}
+		if err := applyPromotion(tx, s.ID, s.Outcome, s.ToYear); err != nil {
+			log.Printf("[ERROR] Failed to apply promotion outcome for student %d: %v", s.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process promotions, nothing was changed"})
+			return
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO promotion_records
+				(school_id, academic_year_id, student_id, from_year, to_year, outcome, credits_passed, credits_required, processed_by_role, processed_by)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			user.SchoolID, yearID, s.ID, s.FromYear, s.ToYear, s.Outcome, s.CreditsPassed, rules.MinCredits, user.Role, user.ID,
+		); err != nil {
+			log.Printf("[ERROR] Failed to record promotion for student %d: %v", s.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process promotions, nothing was changed"})
+			return
+		}
+		counts[s.Outcome]++
+		processed = append(processed, s)
+	}
+
+	if commit {
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process promotions, nothing was changed"})
+			return
+		}
+		log.Printf("[INFO] %s %d ran promotions for academic year %d: %d promoted, %d repeated, %d graduated, %d skipped",
+			user.Role, user.ID, yearID, counts[promotionPromoted], counts[promotionRepeated], counts[promotionGraduated], len(skipped))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run":          !commit,
+		"academic_year_id": yearID,
+		"department":       input.Department,
+		"rules":            rules,
+		"students":         processed,
+		"skipped":          skipped,
+		"promoted":         counts[promotionPromoted],
+		"repeated":         counts[promotionRepeated],
+		"graduated":        counts[promotionGraduated],
+	})
+}
+
+// applyPromotion moves the student to toYear, or makes them alumni. A
+// graduate's waitlist places and pending requests are withdrawn since they
+// can no longer enroll.
+func applyPromotion(tx *sql.Tx, studentID int, outcome, toYear string) error {
+	switch outcome {
+	case promotionPromoted:
+		_, err := tx.Exec("UPDATE students SET year = ? WHERE id = ?", toYear, studentID)
+		return err
+	case promotionGraduated:
+		if _, err := tx.Exec("UPDATE students SET status = ? WHERE id = ?", accountGraduated, studentID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM course_waitlist WHERE student_id = ?", studentID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			UPDATE enrollment_requests
+			SET status = ?, decided_by = NULL, decided_by_role = 'promotion', decision_note = ?, decided_at = NOW()
+			WHERE student_id = ? AND status = ?`,
+			requestRejected, "Student graduated", studentID, requestPending,
+		)
+		return err
+	}
+	return nil
+}
+
+// ListPromotions lists the outcomes recorded for an academic year, the
+// current one unless ?academic_year_id= is given. ?outcome=repeated lists the
+// repeaters.
+func ListPromotions(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	outcome := c.Query("outcome")
+	if outcome != "" && !promotionOutcomes[outcome] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "outcome must be promoted, repeated or graduated"})
+		return
+	}
+
+	var yearID int
+	if v := c.Query("academic_year_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid academic year ID"})
+			return
+		}
+		if !middleware.InSchool(c, middleware.Tenant(c).AcademicYear(id), "Academic year") {
+			return
+		}
+		yearID = id
+	} else {
+		err := database.DB.QueryRow(
+			"SELECT id FROM academic_years WHERE school_id = ? AND is_current = 1", user.SchoolID,
+		).Scan(&yearID)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch academic year"})
+			return
+		}
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT p.student_id, s.fullname, s.registrationNumber, s.department, p.from_year, p.to_year,
+		       p.outcome, p.credits_passed, p.credits_required, p.processed_by_role, p.processed_by, p.created_at
+		FROM promotion_records p
+		JOIN students s ON p.student_id = s.id
+		WHERE p.school_id = ? AND p.academic_year_id = ? AND (? = '' OR p.outcome = ?)
+		ORDER BY s.department ASC, p.from_year ASC, s.fullname ASC`,
+		user.SchoolID, yearID, outcome, outcome,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promotions"})
+		return
+	}
+	defer rows.Close()
+
+	type Record struct {
+		StudentID          int       `json:"student_id"`
+		FullName           string    `json:"fullname"`
+		RegistrationNumber string    `json:"registrationNumber"`
+		Department         string    `json:"department"`
+		FromYear           string    `json:"from_year"`
+		ToYear             string    `json:"to_year"`
+		Outcome            string    `json:"outcome"`
+		CreditsPassed      int       `json:"credits_passed"`
+		CreditsRequired    int       `json:"credits_required"`
+		ProcessedByRole    string    `json:"processed_by_role"`
+		ProcessedBy        int       `json:"processed_by"`
+		CreatedAt          time.Time `json:"created_at"`
+	}
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.StudentID, &r.FullName, &r.RegistrationNumber, &r.Department, &r.FromYear, &r.ToYear,
+			&r.Outcome, &r.CreditsPassed, &r.CreditsRequired, &r.ProcessedByRole, &r.ProcessedBy, &r.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read promotions"})
+			return
+		}
+		records = append(records, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"academic_year_id": yearID, "records": records})
+}
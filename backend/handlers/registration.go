@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/models"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Registration modes a school can choose for student and teacher sign-up.
+const (
+	registrationClosed     = "closed"
+	registrationInviteCode = "invite-code"
+	registrationApproval   = "open-with-approval"
+
+	accountActive    = "active"
+	accountPending   = "pending"
+	accountGraduated = middleware.AccountGraduated
+)
+
+var registrationModes = map[string]bool{
+	registrationClosed:     true,
+	registrationInviteCode: true,
+	registrationApproval:   true,
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// identifierTakenError names the field that is already used in the school.
+type identifierTakenError struct{ field string }
+
+func (e identifierTakenError) Error() string { return e.field + " is already in use" }
+
+// registrationStatus checks the school's policy for a self sign-up and gives
+// the status the new account starts in. It writes the response when sign-up
+// isn't allowed.
+func registrationStatus(c *gin.Context, slug, code string) (int64, string, bool) {
+	var schoolID int64
+	var mode, schoolCode string
+	err := database.DB.QueryRow(
+		"SELECT id, registration_mode, registration_code FROM schools WHERE slug = ? LIMIT 1", slug,
+	).Scan(&schoolID, &mode, &schoolCode)
+	if err != nil {
+		log.Printf("[ERROR] School not found for slug %s: %v", slug, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid school slug"})
+		return 0, "", false
+	}
+
+	switch mode {
+	case registrationInviteCode:
+		if schoolCode == "" || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(code)), []byte(schoolCode)) != 1 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid invite code"})
+			return 0, "", false
+		}
+		return schoolID, accountActive, true
+	case registrationApproval:
+		return schoolID, accountPending, true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "This school doesn't accept sign-ups. Ask the school to create your account."})
+	return 0, "", false
+}
+
+// accountUsable refuses sign-in, after the password has checked out, for an
+// account still waiting for approval.
+func accountUsable(c *gin.Context, status string) bool {
+	if status == accountPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your account is awaiting approval by the school", "status": status})
+		return false
+	}
+	return true
+}
+
+// validateAccount checks the fields every student or teacher account needs.
+func validateAccount(fullname, username, password string) string {
+	if strings.TrimSpace(fullname) == "" || strings.TrimSpace(username) == "" {
+		return "fullname and username are required"
+	}
+	return validatePassword(password)
+}
+
+// takenIdentifier reports which of the account's identifiers another account
+// in the school already uses, or "" if none.
+func takenIdentifier(tx *sql.Tx, table string, schoolID int64, username, column, value string) (string, error) {
+	var taken bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM "+table+" WHERE school_id = ? AND username = ?)", schoolID, username,
+	).Scan(&taken); err != nil || taken {
+		return "username", err
+	}
+	if value == "" {
+		return "", nil
+	}
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM "+table+" WHERE school_id = ? AND "+column+" = ?)", schoolID, value,
+	).Scan(&taken); err != nil || taken {
+		return column, err
+	}
+	return "", nil
+}
+
+// lockSchool serializes account creation within a school, so two sign-ups
+// can't both pass the uniqueness checks with the same identifier.
+func lockSchool(tx *sql.Tx, schoolID int64) error {
+	var id int64
+	return tx.QueryRow("SELECT id FROM schools WHERE id = ? FOR UPDATE", schoolID).Scan(&id)
+}
+
+// createStudent stores a student whose password is already hashed, refusing
+// a username or registration number the school already has.
+func createStudent(student models.Student, schoolID int64, status string) (int64, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := lockSchool(tx, schoolID); err != nil {
+		return 0, err
+	}
+	field, err := takenIdentifier(tx, "students", schoolID, student.Username, "registrationNumber", student.RegistrationNumber)
+	if err != nil {
+		return 0, err
+	}
+	if field != "" {
+		return 0, identifierTakenError{field}
+	}
+	id, err := SaveStudentToDB(tx, student, schoolID, status)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// createTeacher is createStudent for teachers, with employeeId unique instead.
+func createTeacher(teacher models.Teacher, schoolID int64, status string) (int64, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := lockSchool(tx, schoolID); err != nil {
+		return 0, err
+	}
+	field, err := takenIdentifier(tx, "teachers", schoolID, teacher.Username, "employeeId", teacher.EmployeeId)
+	if err != nil {
+		return 0, err
+	}
+	if field != "" {
+		return 0, identifierTakenError{field}
+	}
+	id, err := SaveTeacherToDB(tx, teacher, schoolID, status)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// writeCreateError answers for a failed createStudent or createTeacher.
+func writeCreateError(c *gin.Context, err error, what string) {
+	if taken, ok := err.(identifierTakenError); ok {
+		c.JSON(http.StatusConflict, gin.H{"error": taken.Error(), "field": taken.field})
+		return
+	}
+	if database.IsDuplicate(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "username is already in use", "field": "username"})
+		return
+	}
+	log.Printf("[ERROR] Failed to save %s: %v", what, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save " + what})
+}
+
+// CreateStudentAccount lets staff add a student directly, whatever the
+// school's registration mode. The account is active straight away.
+func CreateStudentAccount(c *gin.Context) {
+	var student models.Student
+	if err := c.ShouldBindJSON(&student); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid student data"})
+		return
+	}
+	if msg := validateAccount(student.FullName, student.Username, student.Password); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	student.CreatedAt = time.Now()
+	student.Password = utils.HashPassword(student.Password)
+
+	id, err := createStudent(student, int64(middleware.CurrentUser(c).SchoolID), accountActive)
+	if err != nil {
+		writeCreateError(c, err, "student")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Student created", "id": id})
+}
+
+// CreateTeacherAccount is CreateStudentAccount for teachers.
+func CreateTeacherAccount(c *gin.Context) {
+	var teacher models.Teacher
+	if err := c.ShouldBindJSON(&teacher); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid teacher data"})
+		return
+	}
+	if msg := validateAccount(teacher.FullName, teacher.Username, teacher.Password); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	teacher.CreatedAt = time.Now()
+	teacher.Password = utils.HashPassword(teacher.Password)
+
+	id, err := createTeacher(teacher, int64(middleware.CurrentUser(c).SchoolID), accountActive)
+	if err != nil {
+		writeCreateError(c, err, "teacher")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Teacher created", "id": id})
+}
+
+// GetRegistrationSettings returns the school's sign-up mode and invite code.
+func GetRegistrationSettings(c *gin.Context) {
+	var mode, code string
+	err := database.DB.QueryRow(
+		"SELECT registration_mode, registration_code FROM schools WHERE id = ?", middleware.CurrentUser(c).SchoolID,
+	).Scan(&mode, &code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registration settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mode": mode, "invite_code": code})
+}
+
+// UpdateRegistrationSettings changes the sign-up mode. Switching to
+// invite-code creates a code if the school has none; regenerate_code replaces
+// it, which stops the old one from working.
+func UpdateRegistrationSettings(c *gin.Context) {
+	var input struct {
+		Mode           string `json:"mode"`
+		RegenerateCode bool   `json:"regenerate_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if !registrationModes[input.Mode] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be closed, invite-code or open-with-approval"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	var code string
+	if err := database.DB.QueryRow(
+		"SELECT registration_code FROM schools WHERE id = ?", user.SchoolID,
+	).Scan(&code); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registration settings"})
+		return
+	}
+	if input.RegenerateCode || (input.Mode == registrationInviteCode && code == "") {
+		code = utils.RandomToken(5)
+	}
+
+	if _, err := database.DB.Exec(
+		"UPDATE schools SET registration_mode = ?, registration_code = ? WHERE id = ?", input.Mode, code, user.SchoolID,
+	); err != nil {
+		log.Printf("[ERROR] Failed to update registration settings for school %d: %v", user.SchoolID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update registration settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Registration settings updated", "mode": input.Mode, "invite_code": code})
+}
+
+// ListPendingRegistrations is the review queue: sign-ups waiting for approval,
+// oldest first.
+func ListPendingRegistrations(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT 'student', id, fullname, username, email, department, year, registrationNumber, created_at
+		FROM students WHERE school_id = ? AND status = ?
+		UNION ALL
+		SELECT 'teacher', id, fullname, username, email, department, year, employeeId, created_at
+		FROM teachers WHERE school_id = ? AND status = ?
+		ORDER BY created_at ASC`,
+		middleware.CurrentUser(c).SchoolID, accountPending, middleware.CurrentUser(c).SchoolID, accountPending,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registrations"})
+		return
+	}
+	defer rows.Close()
+
+	type Registration struct {
+		Role       string    `json:"role"`
+		ID         int       `json:"id"`
+		FullName   string    `json:"fullname"`
+		Username   string    `json:"username"`
+		Email      string    `json:"email"`
+		Department string    `json:"department"`
+		Year       string    `json:"year"`
+		Number     string    `json:"number"` // registration number or employee id
+		CreatedAt  time.Time `json:"created_at"`
+	}
+
+	pending := []Registration{}
+	for rows.Next() {
+		var r Registration
+		if err := rows.Scan(&r.Role, &r.ID, &r.FullName, &r.Username, &r.Email, &r.Department, &r.Year,
+			&r.Number, &r.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read registrations"})
+			return
+		}
+		pending = append(pending, r)
+	}
+
+	c.JSON(http.StatusOK, pending)
+}
+
+// pendingAccount resolves /registrations/:role/:id to a table and id.
+func pendingAccount(c *gin.Context) (string, int, bool) {
+	var table string
+	switch c.Param("role") {
+	case middleware.RoleStudent:
+		table = "students"
+	case middleware.RoleTeacher:
+		table = "teachers"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be student or teacher"})
+		return "", 0, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return "", 0, false
+	}
+	return table, id, true
+}
+
+// ApproveRegistration activates a pending account so it can sign in.
+func ApproveRegistration(c *gin.Context) {
+	table, id, ok := pendingAccount(c)
+	if !ok {
+		return
+	}
+	user := middleware.CurrentUser(c)
+	res, err := database.DB.Exec(
+		"UPDATE "+table+" SET status = ? WHERE id = ? AND school_id = ? AND status = ?",
+		accountActive, id, user.SchoolID, accountPending,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve registration"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending registration not found"})
+		return
+	}
+	log.Printf("[INFO] %s %d approved %s %d", user.Role, user.ID, c.Param("role"), id)
+	if table == "students" {
+		fillSeatsFor(id)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Registration approved"})
+}
+
+// RejectRegistration deletes a pending account, freeing its username.
+func RejectRegistration(c *gin.Context) {
+	table, id, ok := pendingAccount(c)
+	if !ok {
+		return
+	}
+	user := middleware.CurrentUser(c)
+	res, err := database.DB.Exec(
+		"DELETE FROM "+table+" WHERE id = ? AND school_id = ? AND status = ?", id, user.SchoolID, accountPending,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject registration"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending registration not found"})
+		return
+	}
+	log.Printf("[INFO] %s %d rejected %s %d", user.Role, user.ID, c.Param("role"), id)
+	c.JSON(http.StatusOK, gin.H{"message": "Registration rejected"})
+}
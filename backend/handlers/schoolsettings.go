@@ -6,6 +6,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"school-backend/config"
 	"school-backend/database"
 	"strings"
     "github.com/gin-gonic/gin"
@@ -24,7 +26,7 @@ func SaveBase64Image(base64Data, filename string) (string, error) {
 		return "", nil
 	}
 
-	if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
+	if err := os.MkdirAll(config.App.UploadDir, os.ModePerm); err != nil {
 		return "", fmt.Errorf("failed to create uploads dir: %w", err)
 	}
 
@@ -39,13 +41,14 @@ func SaveBase64Image(base64Data, filename string) (string, error) {
 		return "", fmt.Errorf("failed to decode base64: %w", err)
 	}
 
-	path := fmt.Sprintf("uploads/%s", filename)
+	path := filepath.Join(config.App.UploadDir, filename)
 	if err := os.WriteFile(path, decoded, 0644); err != nil {
 		return "", fmt.Errorf("failed to save file: %w", err)
 	}
 
 	log.Printf("✅ Saved image to %s", path)
-	return "/" + path, nil
+	// uploads are always served under /uploads, wherever they live on disk
+	return "/uploads/" + filename, nil
 }
 
 func SchoolSetupHandler(c *gin.Context) {
@@ -169,12 +172,14 @@ func GetSchoolSetupHandler(c *gin.Context) {
         ThemeTemplate   *string `json:"theme_template"`
         LogoText        *string `json:"logo_text"`
         BackgroundColor *string `json:"background_color"`
+        // lets the sign-up page know whether to ask for an invite code
+        RegistrationMode string `json:"registration_mode"`
     }
 
     err := database.DB.QueryRow(`
-        SELECT id, slug, logo_url, background_url, theme_template, logo_text, background_color
+        SELECT id, slug, logo_url, background_url, theme_template, logo_text, background_color, registration_mode
         FROM schools WHERE slug = ?`, slug).
-        Scan(&school.ID, &school.Slug, &school.LogoURL, &school.BackgroundURL, &school.ThemeTemplate, &school.LogoText, &school.BackgroundColor)
+        Scan(&school.ID, &school.Slug, &school.LogoURL, &school.BackgroundURL, &school.ThemeTemplate, &school.LogoText, &school.BackgroundColor, &school.RegistrationMode)
     if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "School not found"})
         return
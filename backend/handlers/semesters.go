@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SemesterInput struct {
+	Name     string `json:"name"`
+	StartsOn string `json:"starts_on"` // "2006-01-02"
+	EndsOn   string `json:"ends_on"`
+	// The add/drop window, both set or both empty.
+	AddDropOpensOn  string `json:"add_drop_opens_on"`
+	AddDropClosesOn string `json:"add_drop_closes_on"`
+	// AcademicYearID files the semester under a year; its dates must fall
+	// inside the year's.
+	AcademicYearID *int `json:"academic_year_id"`
+}
+
+func ListSemesters(c *gin.Context) {
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	rows, err := database.DB.Query(
+		`SELECT id, name, starts_on, ends_on, add_drop_opens_on, add_drop_closes_on, academic_year_id, is_current
+		FROM semesters WHERE school_id = ? ORDER BY starts_on DESC`,
+		schoolID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch semesters"})
+		return
+	}
+	defer rows.Close()
+
+	type Semester struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		StartsOn string `json:"starts_on"`
+		EndsOn   string `json:"ends_on"`
+		// nil when the semester has no add/drop window
+		AddDropOpensOn  *string `json:"add_drop_opens_on"`
+		AddDropClosesOn *string `json:"add_drop_closes_on"`
+		AcademicYearID  *int    `json:"academic_year_id"`
+		Current         bool    `json:"current"`
+	}
+
+	semesters := []Semester{}
+	for rows.Next() {
+		var s Semester
+		var startsOn, endsOn time.Time
+		var opensOn, closesOn sql.NullTime
+		var yearID sql.NullInt64
+		if err := rows.Scan(&s.ID, &s.Name, &startsOn, &endsOn, &opensOn, &closesOn, &yearID, &s.Current); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read semester data"})
+			return
+		}
+		s.StartsOn = startsOn.Format("2006-01-02")
+		s.EndsOn = endsOn.Format("2006-01-02")
+		if opensOn.Valid && closesOn.Valid {
+			opens, closes := opensOn.Time.Format("2006-01-02"), closesOn.Time.Format("2006-01-02")
+			s.AddDropOpensOn, s.AddDropClosesOn = &opens, &closes
+		}
+		if yearID.Valid {
+			id := int(yearID.Int64)
+			s.AcademicYearID = &id
+		}
+		semesters = append(semesters, s)
+	}
+
+	c.JSON(http.StatusOK, semesters)
+}
+
+// SaveSemester sets the dates of a semester by name, creating it if needed.
+// The name matches the free-text semester on class schedules.
+func SaveSemester(c *gin.Context) {
+	var input SemesterInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Semester name is required"})
+		return
+	}
+	startsOn, err1 := time.Parse("2006-01-02", input.StartsOn)
+	endsOn, err2 := time.Parse("2006-01-02", input.EndsOn)
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must be formatted as YYYY-MM-DD"})
+		return
+	}
+	if !endsOn.After(startsOn) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_on must be after starts_on"})
+		return
+	}
+	var opensOn, closesOn interface{}
+	if input.AddDropOpensOn != "" || input.AddDropClosesOn != "" {
+		opens, err1 := time.Parse("2006-01-02", input.AddDropOpensOn)
+		closes, err2 := time.Parse("2006-01-02", input.AddDropClosesOn)
+		if err1 != nil || err2 != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "add_drop_opens_on and add_drop_closes_on must both be set as YYYY-MM-DD"})
+			return
+		}
+		if closes.Before(opens) || closes.After(endsOn) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The add/drop window must close after it opens and before the semester ends"})
+			return
+		}
+		opensOn, closesOn = input.AddDropOpensOn, input.AddDropClosesOn
+	}
+	schoolID := middleware.CurrentUser(c).SchoolID
+	if input.AcademicYearID != nil {
+		if !middleware.InSchool(c, middleware.Tenant(c).AcademicYear(*input.AcademicYearID), "Academic year") {
+			return
+		}
+		var yearStarts, yearEnds time.Time
+		if err := database.DB.QueryRow(
+			"SELECT starts_on, ends_on FROM academic_years WHERE id = ?", *input.AcademicYearID,
+		).Scan(&yearStarts, &yearEnds); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch academic year"})
+			return
+		}
+		if startsOn.Before(yearStarts) || endsOn.After(yearEnds) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The semester must fall within its academic year"})
+			return
+		}
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO semesters (school_id, name, starts_on, ends_on, add_drop_opens_on, add_drop_closes_on, academic_year_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE starts_on = VALUES(starts_on), ends_on = VALUES(ends_on),
+			add_drop_opens_on = VALUES(add_drop_opens_on), add_drop_closes_on = VALUES(add_drop_closes_on),
+			academic_year_id = VALUES(academic_year_id)`,
+		schoolID, input.Name, input.StartsOn, input.EndsOn, opensOn, closesOn, input.AcademicYearID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to save semester %s: %v", input.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save semester"})
+		return
+	}
+
+	var id int
+	if err := database.DB.QueryRow(
+		"SELECT id FROM semesters WHERE school_id = ? AND name = ?", schoolID, input.Name,
+	).Scan(&id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch semester"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Semester saved", "id": id})
+}
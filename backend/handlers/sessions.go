@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"school-backend/config"
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const refreshCookie = "refresh_token"
+
+// Account is the identity a session is opened for.
+type Account struct {
+	ID         int
+	SchoolID   int
+	Role       string
+	FullName   string
+	Department string
+	DBSlug     string
+}
+
+// startSession records a new signed-in device and sets the access and
+// refresh cookies for it.
+func startSession(c *gin.Context, a Account) error {
+	kind, _, _ := accountTable(a.Role)
+	refresh := utils.RandomToken(32)
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	res, err := database.DB.Exec(`
+		INSERT INTO sessions (school_id, owner_kind, owner_id, role, fullname, department, db_slug,
+		                      refresh_hash, user_agent, ip, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), DATE_ADD(NOW(), INTERVAL ? SECOND))`,
+		a.SchoolID, kind, a.ID, a.Role, a.FullName, a.Department, a.DBSlug,
+		utils.HashToken(refresh), userAgent, c.ClientIP(), int(config.App.RefreshTTL.Seconds()),
+	)
+	if err != nil {
+		return err
+	}
+	sid, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	setSessionCookie(c, utils.GenerateJWT(a.ID, a.SchoolID, int(sid), a.FullName, a.Department, a.DBSlug, a.Role))
+	setRefreshCookie(c, refresh)
+	return nil
+}
+
+func setRefreshCookie(c *gin.Context, token string) {
+	c.SetCookie(refreshCookie, token, int(config.App.RefreshTTL.Seconds()), "/",
+		config.App.CookieDomain, config.App.CookieSecure, true)
+}
+
+func clearSessionCookies(c *gin.Context) {
+	c.SetCookie("session_token", "", -1, "/", config.App.CookieDomain, config.App.CookieSecure, true)
+	c.SetCookie(refreshCookie, "", -1, "/", config.App.CookieDomain, config.App.CookieSecure, true)
+}
+
+// RefreshSession swaps a refresh token for a new access token and a new
+// refresh token. Presenting an already-rotated refresh token means it was
+// copied, so the whole session is revoked.
+func RefreshSession(c *gin.Context) {
+	token, _ := c.Cookie(refreshCookie)
+	if token == "" {
+		var input struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		_ = c.ShouldBindJSON(&input)
+		token = input.RefreshToken
+	}
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No session"})
+		return
+	}
+	hash := utils.HashToken(token)
+
+	var a Account
+	var sid int
+	err := database.DB.QueryRow(`
+		SELECT id, owner_id, school_id, role, fullname, department, db_slug
+		FROM sessions
+		WHERE refresh_hash = ? AND revoked_at IS NULL AND expires_at > NOW()`,
+		hash,
+	).Scan(&sid, &a.ID, &a.SchoolID, &a.Role, &a.FullName, &a.Department, &a.DBSlug)
+	if err == sql.ErrNoRows {
+		res, err := database.DB.Exec(
+			"UPDATE sessions SET revoked_at = NOW() WHERE previous_hash = ? AND revoked_at IS NULL", hash,
+		)
+		if err == nil {
+			if n, _ := res.RowsAffected(); n > 0 {
+				log.Printf("[WARN] Refresh token reused, session revoked")
+			}
+		}
+		clearSessionCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	next := utils.RandomToken(32)
+	res, err := database.DB.Exec(`
+		UPDATE sessions SET previous_hash = refresh_hash, refresh_hash = ?, last_used_at = NOW(), ip = ?
+		WHERE id = ? AND refresh_hash = ?`,
+		utils.HashToken(next), c.ClientIP(), sid, hash,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to rotate session %d: %v", sid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		// another request rotated it first
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired"})
+		return
+	}
+
+	setSessionCookie(c, utils.GenerateJWT(a.ID, a.SchoolID, sid, a.FullName, a.Department, a.DBSlug, a.Role))
+	setRefreshCookie(c, next)
+	c.JSON(http.StatusOK, gin.H{"message": "Session refreshed", "role": a.Role})
+}
+
+// ListSessions shows the caller's signed-in devices.
+func ListSessions(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	kind, _, _ := accountTable(user.Role)
+
+	rows, err := database.DB.Query(`
+		SELECT id, user_agent, ip, created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE owner_kind = ? AND owner_id = ? AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC`,
+		kind, user.ID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+	defer rows.Close()
+
+	type Session struct {
+		ID         int       `json:"id"`
+		UserAgent  string    `json:"user_agent"`
+		IP         string    `json:"ip"`
+		CreatedAt  time.Time `json:"created_at"`
+		LastUsedAt time.Time `json:"last_used_at"`
+		ExpiresAt  time.Time `json:"expires_at"`
+		Current    bool      `json:"current"`
+	}
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read sessions"})
+			return
+		}
+		s.Current = s.ID == user.SessionID
+		sessions = append(sessions, s)
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession signs one of the caller's devices out.
+func RevokeSession(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	kind, _, _ := accountTable(user.Role)
+
+	res, err := database.DB.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE id = ? AND owner_kind = ? AND owner_id = ? AND revoked_at IS NULL",
+		c.Param("id"), kind, user.ID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeAllSessions logs the caller out everywhere, this device included.
+func RevokeAllSessions(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	kind, _, _ := accountTable(user.Role)
+
+	revoked, err := revokeSessions(database.DB, kind, user.ID, 0)
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke sessions for %s %d: %v", kind, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	clearSessionCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out everywhere", "revoked": revoked})
+}
+
+// revokeSessions ends every live session of an account except keep (0 keeps none).
+func revokeSessions(db execer, kind string, ownerID, keep int) (int64, error) {
+	res, err := db.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE owner_kind = ? AND owner_id = ? AND id <> ? AND revoked_at IS NULL",
+		kind, ownerID, keep,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"school-backend/config"
+	"school-backend/database"
+	"school-backend/mail"
+	"school-backend/middleware"
+	"school-backend/models"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+// invitableRoles are the roles the main admin can hand out. There is only
+// ever one main admin per school.
+var invitableRoles = map[string]bool{
+	middleware.RoleRegistrar:     true,
+	middleware.RoleAcademicAdmin: true,
+	middleware.RoleFinance:       true,
+	middleware.RoleAuditor:       true,
+}
+
+const invalidInvitation = "Invitation is invalid or has expired"
+
+// InviteStaff emails a sign-up link for a staff role. Inviting the same
+// address again replaces the earlier invitation, which doubles as a resend.
+func InviteStaff(c *gin.Context) {
+	var input struct {
+		Email    string `json:"email"`
+		FullName string `json:"fullname"`
+		Role     string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input.Email = strings.TrimSpace(input.Email)
+	if !strings.Contains(input.Email, "@") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email is required"})
+		return
+	}
+	if !invitableRoles[input.Role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be registrar, academic-admin, finance or auditor"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	var registered bool
+	if err := database.DB.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM users WHERE email = ?)", input.Email,
+	).Scan(&registered); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	if registered {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already registered"})
+		return
+	}
+
+	var schoolName string
+	if err := database.DB.QueryRow("SELECT name FROM schools WHERE id = ?", user.SchoolID).Scan(&schoolName); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch school"})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE staff_invitations SET revoked_at = NOW()
+		WHERE school_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL`,
+		user.SchoolID, input.Email,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	token := utils.RandomToken(32)
+	res, err := tx.Exec(`
+		INSERT INTO staff_invitations (school_id, email, fullname, role, token_hash, invited_by, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))`,
+		user.SchoolID, input.Email, input.FullName, input.Role, utils.HashToken(token), user.ID,
+		int(invitationTTL.Seconds()),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to store invitation for %s: %v", input.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	link := config.App.FrontendURL + "/accept-invite?token=" + url.QueryEscape(token)
+	err = mail.Send(mail.Message{
+		To:      input.Email,
+		Subject: "You've been invited to " + schoolName,
+		Body: "You've been invited to join " + schoolName + " as " + input.Role + ".\n\n" +
+			"Open this link within " + invitationTTL.String() + " to set your password:\n" + link + "\n",
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to send invitation %d: %v", id, err)
+	}
+
+	log.Printf("[INFO] %s %d invited %s as %s", user.Role, user.ID, input.Email, input.Role)
+	c.JSON(http.StatusCreated, gin.H{"message": "Invitation sent", "id": id})
+}
+
+// ListStaffInvitations shows invitations that can still be accepted.
+func ListStaffInvitations(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT id, email, fullname, role, invited_by, expires_at, created_at
+		FROM staff_invitations
+		WHERE school_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC`,
+		middleware.CurrentUser(c).SchoolID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+	defer rows.Close()
+
+	invitations := []models.StaffInvitation{}
+	for rows.Next() {
+		var inv models.StaffInvitation
+		if err := rows.Scan(&inv.ID, &inv.Email, &inv.FullName, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read invitations"})
+			return
+		}
+		invitations = append(invitations, inv)
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func RevokeStaffInvitation(c *gin.Context) {
+	res, err := database.DB.Exec(`
+		UPDATE staff_invitations SET revoked_at = NOW()
+		WHERE id = ? AND school_id = ? AND accepted_at IS NULL AND revoked_at IS NULL`,
+		c.Param("id"), middleware.CurrentUser(c).SchoolID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// GetStaffInvitation lets the accept page show who the invitation is for.
+func GetStaffInvitation(c *gin.Context) {
+	var email, fullname, role, schoolName, slug string
+	err := database.DB.QueryRow(`
+		SELECT i.email, i.fullname, i.role, sch.name, sch.slug
+		FROM staff_invitations i
+		JOIN schools sch ON i.school_id = sch.id
+		WHERE i.token_hash = ? AND i.accepted_at IS NULL AND i.revoked_at IS NULL AND i.expires_at > NOW()`,
+		utils.HashToken(c.Param("token")),
+	).Scan(&email, &fullname, &role, &schoolName, &slug)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": invalidInvitation})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"email": email, "fullname": fullname, "role": role, "school": schoolName, "slug": slug})
+}
+
+// AcceptStaffInvitation creates the invited account with the chosen password.
+// The invitation is claimed and the account created in one transaction, so a
+// token can't make two accounts.
+func AcceptStaffInvitation(c *gin.Context) {
+	var input struct {
+		Token       string `json:"token"`
+		Password    string `json:"password"`
+		FullName    string `json:"fullname"`
+		PhoneNumber string `json:"phonenumber"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if msg := validatePassword(input.Password); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+	defer tx.Rollback()
+
+	hash := utils.HashToken(input.Token)
+	res, err := tx.Exec(`
+		UPDATE staff_invitations SET accepted_at = NOW()
+		WHERE token_hash = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()`,
+		hash,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidInvitation})
+		return
+	}
+
+	var schoolID int
+	var email, fullname, role, slug string
+	if err := tx.QueryRow(`
+		SELECT i.school_id, i.email, i.fullname, i.role, sch.slug
+		FROM staff_invitations i
+		JOIN schools sch ON i.school_id = sch.id
+		WHERE i.token_hash = ?`,
+		hash,
+	).Scan(&schoolID, &email, &fullname, &role, &slug); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+	if name := strings.TrimSpace(input.FullName); name != "" {
+		fullname = name
+	}
+	if fullname == "" {
+		fullname = email
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO users (email, password_hash, role, school_id, phonenumber, fullname) VALUES (?, ?, ?, ?, ?, ?)",
+		email, utils.HashPassword(input.Password), role, schoolID, input.PhoneNumber, fullname,
+	)
+	if database.IsDuplicate(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already registered"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create account for invitation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	log.Printf("[INFO] %s accepted an invitation to school %d as %s", email, schoolID, role)
+	c.JSON(http.StatusCreated, gin.H{"message": "Account created, you can now sign in", "role": role, "slug": slug})
+}
+
+// ListStaff shows everyone who signs in to the school by email.
+func ListStaff(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT id, email, fullname, role, phonenumber, created_at
+		FROM users
+		WHERE school_id = ?
+		ORDER BY role = ? DESC, fullname ASC`,
+		middleware.CurrentUser(c).SchoolID, middleware.RoleMainAdmin,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch staff"})
+		return
+	}
+	defer rows.Close()
+
+	staff := []models.StaffMember{}
+	for rows.Next() {
+		var s models.StaffMember
+		if err := rows.Scan(&s.ID, &s.Email, &s.FullName, &s.Role, &s.PhoneNumber, &s.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read staff"})
+			return
+		}
+		staff = append(staff, s)
+	}
+
+	c.JSON(http.StatusOK, staff)
+}
+
+// staffMember loads a users row of the caller's school that the main admin
+// may change: not themselves and not another main admin.
+func staffMember(c *gin.Context) (int, bool) {
+	user := middleware.CurrentUser(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid staff ID"})
+		return 0, false
+	}
+
+	var role string
+	err = database.DB.QueryRow("SELECT role FROM users WHERE id = ? AND school_id = ?", id, user.SchoolID).Scan(&role)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Staff member not found"})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch staff member"})
+		return 0, false
+	}
+	if id == user.ID || role == middleware.RoleMainAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The school owner's account can't be changed here"})
+		return 0, false
+	}
+	return id, true
+}
+
+// UpdateStaffRole moves a staff member to another role. Their sessions are
+// ended because the role is carried in the access token.
+func UpdateStaffRole(c *gin.Context) {
+	var input struct {
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if !invitableRoles[input.Role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be registrar, academic-admin, finance or auditor"})
+		return
+	}
+	id, ok := staffMember(c)
+	if !ok {
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE users SET role = ? WHERE id = ?", input.Role, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	if _, err := revokeSessions(tx, "user", id, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	log.Printf("[INFO] %s %d changed the role of user %d to %s", user.Role, user.ID, id, input.Role)
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated", "role": input.Role})
+}
+
+// RemoveStaff deletes a staff account and signs it out everywhere.
+func RemoveStaff(c *gin.Context) {
+	id, ok := staffMember(c)
+	if !ok {
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove staff member"})
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove staff member"})
+		return
+	}
+	if _, err := revokeSessions(tx, "user", id, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove staff member"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove staff member"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	log.Printf("[INFO] %s %d removed user %d", user.Role, user.ID, id)
+	c.JSON(http.StatusOK, gin.H{"message": "Staff member removed"})
+}
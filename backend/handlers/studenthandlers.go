@@ -4,17 +4,34 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+
 	"school-backend/database"
+	"school-backend/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AssignStudentCoursesPayload struct {
 	CourseIDs []int `json:"course_ids"`
+	// Override lets staff enroll despite unmet requirements; Reason is
+	// recorded with it.
+	Override bool   `json:"override"`
+	Reason   string `json:"reason"`
 }
 
+// AssignCoursesToStudent enrolls a student in courses they meet the
+// requirements of. If any course has unmet requirements nothing is enrolled
+// and the unmet list is returned, unless staff override it with a reason.
+// Full courses put the student on the waitlist instead. Outside the add/drop
+// period a student's own adds become requests for an admin to approve.
+// Enrollments go into the current term; staff may pick another with
+// ?term_id=.
 func AssignCoursesToStudent(c *gin.Context) {
-	studentID := c.Param("id")
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
 	var payload AssignStudentCoursesPayload
 
 	if err := c.BindJSON(&payload); err != nil {
@@ -22,46 +39,172 @@ func AssignCoursesToStudent(c *gin.Context) {
 		return
 	}
 
-	for _, courseID := range payload.CourseIDs {
-		// Check if already assigned
+	user := middleware.CurrentUser(c)
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	if payload.Override && user.Role == middleware.RoleStudent {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only staff can override course requirements"})
+		return
+	}
+	if payload.Override && payload.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to override course requirements"})
+		return
+	}
+	if len(payload.Reason) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason must be at most 500 characters"})
+		return
+	}
+
+	if user.Role == middleware.RoleStudent && c.Query("term_id") != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Students can only enroll in the current term"})
+		return
+	}
+	termID, ok := singleTerm(c)
+	if !ok {
+		return
+	}
+
+	courseIDs := uniqueInts(payload.CourseIDs)
+	tenant := middleware.Tenant(c)
+	for _, courseID := range courseIDs {
+		if !middleware.InSchool(c, tenant.Course(courseID), "Course") {
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign course"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Check if already assigned
+	var newIDs []int
+	for _, courseID := range courseIDs {
 		var exists bool
-		err := database.DB.QueryRow(
-			"SELECT EXISTS (SELECT 1 FROM student_courses WHERE student_id = ? AND course_id = ?)",
-			studentID, courseID,
+		err := tx.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM student_courses WHERE student_id = ? AND course_id = ? AND term_id = ?)",
+			studentID, courseID, termID,
 		).Scan(&exists)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Check failed"})
 			return
 		}
+		if !exists {
+			newIDs = append(newIDs, courseID)
+		}
+	}
+
+	unmet, err := checkEligibility(tx, studentID, termID, newIDs)
+	if err != nil {
+		log.Printf("[ERROR] Eligibility check for student %d failed: %v", studentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Check failed"})
+		return
+	}
+	var ineligible []CourseEligibility
+	for _, e := range eligibilityList(newIDs, unmet) {
+		if !e.Eligible {
+			ineligible = append(ineligible, e)
+		}
+	}
+	if len(ineligible) > 0 && !payload.Override {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Course requirements not met, no courses were assigned",
+			"courses": ineligible,
+		})
+		return
+	}
 
-		if exists {
-			continue
+	if user.Role == middleware.RoleStudent && len(newIDs) > 0 {
+		open, _, err := addDropStatus(tx, user.SchoolID, termID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check add/drop period"})
+			return
+		}
+		if !open {
+			requestIDs := []int{}
+			for _, courseID := range newIDs {
+				id, err := requestEnrollmentChange(tx, studentID, courseID, termID, enrollmentAdd, payload.Reason)
+				if err != nil {
+					log.Printf("[ERROR] Failed to request course %d for student %d: %v", courseID, studentID, err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request courses"})
+					return
+				}
+				requestIDs = append(requestIDs, id)
+			}
+			if err := tx.Commit(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request courses"})
+				return
+			}
+			c.JSON(http.StatusAccepted, gin.H{
+				"message":     "The add/drop period is closed, so the courses were sent to an administrator for approval",
+				"request_ids": requestIDs,
+			})
+			return
 		}
+	}
 
-		// Insert assignment
-		_, err = database.DB.Exec(
-			"INSERT INTO student_courses (student_id, course_id) VALUES (?, ?)",
-			studentID, courseID,
-		)
+	type Waitlisted struct {
+		CourseID int `json:"course_id"`
+		Position int `json:"position"`
+	}
+	enrolled, waitlisted := []int{}, []Waitlisted{}
+	for _, courseID := range newIDs {
+		position, err := enrollOrWaitlist(tx, studentID, courseID, termID, user, payload.Reason)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign course"})
 			return
 		}
+		if len(unmet[courseID]) > 0 {
+			if err := recordOverride(tx, studentID, courseID, user, payload.Reason, unmet[courseID]); err != nil {
+				log.Printf("[ERROR] Failed to record override for student %d course %d: %v", studentID, courseID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign course"})
+				return
+			}
+		}
+		if position > 0 {
+			waitlisted = append(waitlisted, Waitlisted{CourseID: courseID, Position: position})
+		} else {
+			enrolled = append(enrolled, courseID)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign course"})
+		return
+	}
+	for _, e := range ineligible {
+		log.Printf("[INFO] %s %d overrode requirements to enroll student %d in course %d: %s",
+			user.Role, user.ID, studentID, e.CourseID, payload.Reason)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Courses assigned to student"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Courses assigned to student",
+		"enrolled":   enrolled,
+		"waitlisted": waitlisted,
+		"overridden": len(ineligible),
+	})
 }
 
+// GetStudentCourses lists the student's courses in the current term, or the
+// ?term_id= given ("all" for every term).
 func GetStudentCourses(c *gin.Context) {
-	studentID := c.Param("id")
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+	scope, ok := middleware.RequestedTerm(c)
+	if !ok {
+		return
+	}
+	inTerm, termArgs := scope.Where("sc.term_id")
 
 	query := `
-        SELECT c.id, c.name, c.code
+        SELECT c.id, c.name, c.code, sc.term_id
         FROM student_courses sc
         JOIN courses c ON sc.course_id = c.id
-        WHERE sc.student_id = ?
+        WHERE sc.student_id = ? AND ` + inTerm + `
     `
-	rows, err := database.DB.Query(query, studentID)
+	rows, err := database.DB.Query(query, append([]interface{}{studentID}, termArgs...)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch courses"})
 		return
@@ -69,15 +212,16 @@ func GetStudentCourses(c *gin.Context) {
 	defer rows.Close()
 
 	type Course struct {
-		ID   int    `json:"id"`
-		Name string `json:"name"`
-		Code string `json:"code"`
+		ID     int    `json:"id"`
+		Name   string `json:"name"`
+		Code   string `json:"code"`
+		TermID int    `json:"term_id"`
 	}
 
 	var courses []Course
 	for rows.Next() {
 		var course Course
-		if err := rows.Scan(&course.ID, &course.Name, &course.Code); err != nil {
+		if err := rows.Scan(&course.ID, &course.Name, &course.Code, &course.TermID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read course data"})
 			return
 		}
@@ -90,23 +234,37 @@ func GetStudentCourses(c *gin.Context) {
 
 
 func GetCoursesByStudentDepartment(c *gin.Context) {
-    studentID := c.Param("id")
+    studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+    if !ok {
+        return
+    }
+
+    schoolID := middleware.CurrentUser(c).SchoolID
 
     var department string
-    err := database.DB.QueryRow("SELECT department FROM students WHERE id = ?", studentID).Scan(&department)
+    err := database.DB.QueryRow(
+        "SELECT department FROM students WHERE id = ? AND school_id = ?",
+        studentID, schoolID,
+    ).Scan(&department)
     if err != nil || department == "" {
         c.JSON(http.StatusNotFound, gin.H{"error": "Student or department not found"})
         return
     }
 
     var departmentID int
-    err = database.DB.QueryRow("SELECT id FROM departments WHERE name = ?", department).Scan(&departmentID)
+    err = database.DB.QueryRow(
+        "SELECT id FROM departments WHERE name = ? AND school_id = ?",
+        department, schoolID,
+    ).Scan(&departmentID)
     if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
         return
     }
 
-    rows, err := database.DB.Query("SELECT id, name, code FROM courses WHERE department_id = ?", departmentID)
+    rows, err := database.DB.Query(
+        "SELECT id, name, code FROM courses WHERE department_id = ? AND school_id = ? AND active = 1",
+        departmentID, schoolID,
+    )
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch courses"})
         return
@@ -129,9 +287,17 @@ func GetCoursesByStudentDepartment(c *gin.Context) {
 
 func GetStudentsForTeacher(c *gin.Context) {
     slug := c.Param("slug")    // school slug
-    teacherID := c.Param("id") // teacher ID
+    teacherID, ok := middleware.SubjectID(c, middleware.RoleTeacher)
+    if !ok {
+        return
+    }
+    scope, ok := middleware.RequestedTerm(c)
+    if !ok {
+        return
+    }
+    inTerm, termArgs := scope.Where("sc.term_id")
 
-    log.Printf("[DEBUG] GetStudentsForTeacher called with teacherID=%s, slug=%s", teacherID, slug)
+    log.Printf("[DEBUG] GetStudentsForTeacher called with teacherID=%d, slug=%s", teacherID, slug)
 
     // ✅ FIXED QUERY:
     query := `
@@ -154,12 +320,13 @@ func GetStudentsForTeacher(c *gin.Context) {
             tc.teacher_id = ?
         AND 
             sch.slug = ?
+        AND ` + inTerm + `
         ORDER BY s.fullname ASC, c.name ASC
     `
 
     log.Printf("[DEBUG] Executing query: %s", query)
 
-    rows, err := database.DB.Query(query, teacherID, slug)
+    rows, err := database.DB.Query(query, append([]interface{}{teacherID, slug}, termArgs...)...)
     if err != nil {
         log.Printf("[ERROR] Query failed: %v", err)
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Query failed"})
@@ -213,14 +380,16 @@ func GetStudentsForTeacher(c *gin.Context) {
 
 
 func GetStudentClasses(c *gin.Context) {
-	studentID := c.Param("id")
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
 	fmt.Println("🔍 Received request to get classes for student ID:", studentID)
-
-	if studentID == "" {
-		fmt.Println("❌ Student ID is empty!")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing student ID"})
+	scope, ok := middleware.RequestedTerm(c)
+	if !ok {
 		return
 	}
+	inTerm, termArgs := scope.Where("sc.term_id")
 
 	var classes []struct {
 		CourseName string `json:"course_name"`
@@ -240,16 +409,16 @@ func GetStudentClasses(c *gin.Context) {
 		cs.venue,
 		cs.semester
 	FROM student_courses sc
-	JOIN class_schedules cs ON sc.course_id = cs.course_id
+	JOIN class_schedules cs ON sc.course_id = cs.course_id AND sc.term_id = cs.term_id
 	JOIN courses ON courses.id = cs.course_id
-	WHERE sc.student_id = ?
+	WHERE sc.student_id = ? AND ` + inTerm + `
 	ORDER BY FIELD(cs.day_of_week, 'Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday', 'Sunday'),
 	         cs.start_time ASC
 	`
 
 	fmt.Println("📡 Executing query to fetch class schedule for student:", studentID)
 
-	rows, err := database.DB.Query(query, studentID)
+	rows, err := database.DB.Query(query, append([]interface{}{studentID}, termArgs...)...)
 	if err != nil {
 		fmt.Println("❌ Error executing query:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch classes"})
@@ -283,7 +452,7 @@ func GetStudentClasses(c *gin.Context) {
 		count++
 	}
 
-	fmt.Printf("✅ Fetched %d classes for student ID %s\n", count, studentID)
+	fmt.Printf("✅ Fetched %d classes for student ID %d\n", count, studentID)
 
 	if count == 0 {
 		fmt.Println("⚠️ No classes found for student ID:", studentID)
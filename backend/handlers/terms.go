@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AcademicYearInput struct {
+	Name     string `json:"name"`      // e.g. "2026/2027"
+	StartsOn string `json:"starts_on"` // "2006-01-02"
+	EndsOn   string `json:"ends_on"`
+}
+
+type Term struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	StartsOn       string `json:"starts_on"`
+	EndsOn         string `json:"ends_on"`
+	AcademicYearID *int   `json:"academic_year_id"`
+	AcademicYear   string `json:"academic_year"`
+	Current        bool   `json:"current"`
+}
+
+// schoolTerms lists a school's terms, newest first.
+func schoolTerms(schoolID int) ([]Term, error) {
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.name, s.starts_on, s.ends_on, s.academic_year_id, COALESCE(y.name, ''), s.is_current
+		FROM semesters s
+		LEFT JOIN academic_years y ON s.academic_year_id = y.id
+		WHERE s.school_id = ?
+		ORDER BY s.starts_on DESC`,
+		schoolID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terms := []Term{}
+	for rows.Next() {
+		var t Term
+		var startsOn, endsOn time.Time
+		var yearID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.Name, &startsOn, &endsOn, &yearID, &t.AcademicYear, &t.Current); err != nil {
+			return nil, err
+		}
+		t.StartsOn = startsOn.Format("2006-01-02")
+		t.EndsOn = endsOn.Format("2006-01-02")
+		if yearID.Valid {
+			id := int(yearID.Int64)
+			t.AcademicYearID = &id
+		}
+		terms = append(terms, t)
+	}
+	return terms, rows.Err()
+}
+
+// ListTerms gives every member of the school the terms they can look back
+// on, marking the current one.
+func ListTerms(c *gin.Context) {
+	terms, err := schoolTerms(middleware.CurrentUser(c).SchoolID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch terms"})
+		return
+	}
+	c.JSON(http.StatusOK, terms)
+}
+
+// ListAcademicYears returns the school's years, newest first, each with its
+// terms.
+func ListAcademicYears(c *gin.Context) {
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	rows, err := database.DB.Query(
+		"SELECT id, name, starts_on, ends_on, is_current FROM academic_years WHERE school_id = ? ORDER BY starts_on DESC",
+		schoolID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch academic years"})
+		return
+	}
+	defer rows.Close()
+
+	type Year struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		StartsOn string `json:"starts_on"`
+		EndsOn   string `json:"ends_on"`
+		Current  bool   `json:"current"`
+		Terms    []Term `json:"terms"`
+	}
+	years := []*Year{}
+	byID := map[int]*Year{}
+	for rows.Next() {
+		y := &Year{Terms: []Term{}}
+		var startsOn, endsOn time.Time
+		if err := rows.Scan(&y.ID, &y.Name, &startsOn, &endsOn, &y.Current); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read academic years"})
+			return
+		}
+		y.StartsOn = startsOn.Format("2006-01-02")
+		y.EndsOn = endsOn.Format("2006-01-02")
+		years = append(years, y)
+		byID[y.ID] = y
+	}
+	rows.Close()
+
+	terms, err := schoolTerms(schoolID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch terms"})
+		return
+	}
+	// terms come newest first; list them in the order they happen
+	for i := len(terms) - 1; i >= 0; i-- {
+		if t := terms[i]; t.AcademicYearID != nil && byID[*t.AcademicYearID] != nil {
+			byID[*t.AcademicYearID].Terms = append(byID[*t.AcademicYearID].Terms, t)
+		}
+	}
+
+	c.JSON(http.StatusOK, years)
+}
+
+// SaveAcademicYear sets the dates of an academic year by name, creating it
+// if needed.
+func SaveAcademicYear(c *gin.Context) {
+	var input AcademicYearInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Academic year name is required and must be at most 50 characters"})
+		return
+	}
+	startsOn, err1 := time.Parse("2006-01-02", input.StartsOn)
+	endsOn, err2 := time.Parse("2006-01-02", input.EndsOn)
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must be formatted as YYYY-MM-DD"})
+		return
+	}
+	if !endsOn.After(startsOn) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_on must be after starts_on"})
+		return
+	}
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	// an existing year can't shrink past the terms filed under it
+	var outside bool
+	if err := database.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM semesters s JOIN academic_years y ON s.academic_year_id = y.id
+			WHERE y.school_id = ? AND y.name = ? AND (s.starts_on < ? OR s.ends_on > ?))`,
+		schoolID, input.Name, input.StartsOn, input.EndsOn,
+	).Scan(&outside); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check terms"})
+		return
+	}
+	if outside {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The year's dates must cover all of its terms"})
+		return
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO academic_years (school_id, name, starts_on, ends_on) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE starts_on = VALUES(starts_on), ends_on = VALUES(ends_on)`,
+		schoolID, input.Name, input.StartsOn, input.EndsOn,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to save academic year %s: %v", input.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save academic year"})
+		return
+	}
+
+	var id int
+	if err := database.DB.QueryRow(
+		"SELECT id FROM academic_years WHERE school_id = ? AND name = ?", schoolID, input.Name,
+	).Scan(&id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch academic year"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Academic year saved", "id": id})
+}
+
+// SetCurrentAcademicYear marks the year current. The current term is left
+// alone unless it belongs to another year, in which case none is current
+// until one is picked.
+func SetCurrentAcademicYear(c *gin.Context) {
+	yearID := c.Param("id")
+	if !middleware.InSchool(c, middleware.Tenant(c).AcademicYear(yearID), "Academic year") {
+		return
+	}
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set current year"})
+		return
+	}
+	defer tx.Rollback()
+
+	fail := func(err error) {
+		log.Printf("[ERROR] Failed to set current academic year %s: %v", yearID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set current year"})
+	}
+	if _, err := tx.Exec("UPDATE academic_years SET is_current = (id = ?) WHERE school_id = ?", yearID, schoolID); err != nil {
+		fail(err)
+		return
+	}
+	if _, err := tx.Exec(`
+		UPDATE semesters SET is_current = 0
+		WHERE school_id = ? AND is_current = 1 AND (academic_year_id IS NULL OR academic_year_id <> ?)`,
+		schoolID, yearID,
+	); err != nil {
+		fail(err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		fail(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Current academic year set"})
+}
+
+// SetCurrentTerm marks the term current, along with its academic year. New
+// enrollments go into the current term, and listings show it by default.
+func SetCurrentTerm(c *gin.Context) {
+	termID := c.Param("id")
+	if !middleware.InSchool(c, middleware.Tenant(c).Term(termID), "Term") {
+		return
+	}
+	schoolID := middleware.CurrentUser(c).SchoolID
+
+	var yearID sql.NullInt64
+	if err := database.DB.QueryRow("SELECT academic_year_id FROM semesters WHERE id = ?", termID).Scan(&yearID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch term"})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set current term"})
+		return
+	}
+	defer tx.Rollback()
+
+	fail := func(err error) {
+		log.Printf("[ERROR] Failed to set current term %s: %v", termID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set current term"})
+	}
+	if _, err := tx.Exec("UPDATE semesters SET is_current = (id = ?) WHERE school_id = ?", termID, schoolID); err != nil {
+		fail(err)
+		return
+	}
+	if yearID.Valid {
+		if _, err := tx.Exec(
+			"UPDATE academic_years SET is_current = (id = ?) WHERE school_id = ?", yearID.Int64, schoolID,
+		); err != nil {
+			fail(err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		fail(err)
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	log.Printf("[INFO] %s %d set term %s current for school %d", user.Role, user.ID, termID, schoolID)
+	c.JSON(http.StatusOK, gin.H{"message": "Current term set"})
+}
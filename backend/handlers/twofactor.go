@@ -0,0 +1,623 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	challengeTTL          = 5 * time.Minute
+	maxChallengeAttempts  = 5
+	recoveryCodeCount     = 10
+	enrollmentQRSize      = 256 // pixels
+	secondFactorExpired   = "Sign-in has expired, please start again"
+	secondFactorIncorrect = "Invalid authentication code"
+)
+
+// twoFactorState is an account's enrollment: enabled once a code has been
+// confirmed, pending while a secret has been issued but not confirmed.
+type twoFactorState struct {
+	Secret       string
+	Enabled      bool
+	Pending      bool
+	LastUsedStep int64
+}
+
+func loadTwoFactor(kind string, id int) (twoFactorState, error) {
+	var s twoFactorState
+	var enabledAt sql.NullTime
+	err := database.DB.QueryRow(
+		"SELECT secret, enabled_at, last_used_step FROM two_factor WHERE owner_kind = ? AND owner_id = ?", kind, id,
+	).Scan(&s.Secret, &enabledAt, &s.LastUsedStep)
+	if err == sql.ErrNoRows {
+		return s, nil
+	}
+	s.Enabled = enabledAt.Valid
+	s.Pending = !enabledAt.Valid
+	return s, err
+}
+
+// twoFactorMandatory reports whether the school makes 2FA compulsory for the
+// account. It applies to staff accounts, not teachers.
+func twoFactorMandatory(kind string, schoolID int) (bool, error) {
+	if kind != "user" {
+		return false, nil
+	}
+	var required bool
+	err := database.DB.QueryRow("SELECT require_admin_2fa FROM schools WHERE id = ?", schoolID).Scan(&required)
+	return required, err
+}
+
+// requireSecondFactor is called once a password has checked out. If the
+// account uses 2FA, or its school requires it, it answers with a challenge to
+// finish at /login/2fa instead of opening a session, and returns true. It
+// also returns true after writing an error.
+func requireSecondFactor(c *gin.Context, a Account) bool {
+	kind, _, _ := accountTable(a.Role)
+	state, err := loadTwoFactor(kind, a.ID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load 2FA for %s %d: %v", kind, a.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return true
+	}
+	mandatory, err := twoFactorMandatory(kind, a.SchoolID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load 2FA policy for school %d: %v", a.SchoolID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return true
+	}
+	if !state.Enabled && !mandatory {
+		return false
+	}
+
+	token := utils.RandomToken(32)
+	_, err = database.DB.Exec(`
+		INSERT INTO login_challenges (token_hash, school_id, owner_kind, owner_id, role, fullname, department, db_slug, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))`,
+		utils.HashToken(token), a.SchoolID, kind, a.ID, a.Role, a.FullName, a.Department, a.DBSlug,
+		int(challengeTTL.Seconds()),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to store login challenge for %s %d: %v", kind, a.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return true
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"two_factor_required": true,
+		// the school requires 2FA but the account hasn't enrolled yet
+		"setup_required": !state.Enabled,
+		"challenge":      token,
+	})
+	return true
+}
+
+// loadChallenge finds a live challenge. countAttempt uses up one of its
+// attempts, which is what limits guessing codes.
+func loadChallenge(token string, countAttempt bool) (Account, string, bool, error) {
+	hash := utils.HashToken(token)
+	if countAttempt {
+		res, err := database.DB.Exec(`
+			UPDATE login_challenges SET attempts = attempts + 1
+			WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW() AND attempts < ?`,
+			hash, maxChallengeAttempts,
+		)
+		if err != nil {
+			return Account{}, "", false, err
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			return Account{}, "", false, nil
+		}
+	}
+
+	var a Account
+	var kind string
+	err := database.DB.QueryRow(`
+		SELECT owner_id, school_id, owner_kind, role, fullname, department, db_slug
+		FROM login_challenges
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW()`,
+		hash,
+	).Scan(&a.ID, &a.SchoolID, &kind, &a.Role, &a.FullName, &a.Department, &a.DBSlug)
+	if err == sql.ErrNoRows {
+		return a, "", false, nil
+	}
+	return a, kind, err == nil, err
+}
+
+// TwoFactorLoginSetup lets an account that must use 2FA but hasn't enrolled
+// get a secret during sign-in, using its challenge.
+func TwoFactorLoginSetup(c *gin.Context) {
+	var input struct {
+		Challenge string `json:"challenge"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	a, kind, ok, err := loadChallenge(input.Challenge, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sign-in"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": secondFactorExpired})
+		return
+	}
+	beginEnrollment(c, kind, a.ID, a.SchoolID, a.FullName)
+}
+
+// TwoFactorLogin finishes a sign-in with an authenticator code or a recovery
+// code. During mandatory enrollment the first valid code also turns 2FA on,
+// and the new recovery codes are returned once.
+func TwoFactorLogin(c *gin.Context) {
+	var input struct {
+		Challenge    string `json:"challenge"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !allowLogin(c, "") {
+		return
+	}
+	a, kind, ok, err := loadChallenge(input.Challenge, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sign-in"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": secondFactorExpired})
+		return
+	}
+
+	state, err := loadTwoFactor(kind, a.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+
+	var verified bool
+	switch {
+	case input.RecoveryCode != "" && state.Enabled:
+		verified, err = useRecoveryCode(kind, a.ID, input.RecoveryCode)
+	case state.Secret != "":
+		verified, err = useTOTPCode(kind, a.ID, state, input.Code)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set up an authenticator first"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to verify second factor for %s %d: %v", kind, a.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+	if !verified {
+		// a fresh challenge only costs the password, so wrong codes also
+		// count towards the client IP's login throttle
+		if err := recordLoginFailure(throttleIP, c.ClientIP(), a.SchoolID); err != nil {
+			log.Printf("[ERROR] Failed to record failed login for ip %s: %v", c.ClientIP(), err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": secondFactorIncorrect})
+		return
+	}
+
+	var recoveryCodes []string
+	if !state.Enabled {
+		if recoveryCodes, err = enableTwoFactor(kind, a.ID); err != nil {
+			log.Printf("[ERROR] Failed to enable 2FA for %s %d: %v", kind, a.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+			return
+		}
+	}
+
+	res, err := database.DB.Exec(
+		"UPDATE login_challenges SET used_at = NOW() WHERE token_hash = ? AND used_at IS NULL",
+		utils.HashToken(input.Challenge),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": secondFactorExpired})
+		return
+	}
+
+	if err := startSession(c, a); err != nil {
+		log.Printf("[ERROR] Failed to start session for %s %d: %v", kind, a.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	resp := gin.H{"message": "Login successful", "role": a.Role, "slug": a.DBSlug, "fullname": a.FullName, "department": a.Department}
+	if recoveryCodes != nil {
+		resp["recovery_codes"] = recoveryCodes
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// useTOTPCode accepts a code once: the step it matched must be later than the
+// last one used.
+func useTOTPCode(kind string, id int, state twoFactorState, code string) (bool, error) {
+	step, ok := utils.VerifyTOTP(state.Secret, code, time.Now())
+	if !ok || step <= state.LastUsedStep {
+		return false, nil
+	}
+	res, err := database.DB.Exec(
+		"UPDATE two_factor SET last_used_step = ? WHERE owner_kind = ? AND owner_id = ? AND last_used_step < ?",
+		step, kind, id, step,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func useRecoveryCode(kind string, id int, code string) (bool, error) {
+	res, err := database.DB.Exec(`
+		UPDATE two_factor_recovery_codes SET used_at = NOW()
+		WHERE owner_kind = ? AND owner_id = ? AND code_hash = ? AND used_at IS NULL`,
+		kind, id, utils.HashToken(normalizeRecoveryCode(code)),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// enableTwoFactor confirms enrollment and issues a fresh set of recovery codes.
+func enableTwoFactor(kind string, id int) ([]string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE two_factor SET enabled_at = NOW() WHERE owner_kind = ? AND owner_id = ?", kind, id,
+	); err != nil {
+		return nil, err
+	}
+	codes, err := replaceRecoveryCodes(tx, kind, id)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, kind string, id int) ([]string, error) {
+	if _, err := tx.Exec(
+		"DELETE FROM two_factor_recovery_codes WHERE owner_kind = ? AND owner_id = ?", kind, id,
+	); err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := utils.RandomToken(5)
+		codes[i] = raw[:5] + "-" + raw[5:]
+		if _, err := tx.Exec(
+			"INSERT INTO two_factor_recovery_codes (owner_kind, owner_id, code_hash) VALUES (?, ?, ?)",
+			kind, id, utils.HashToken(raw),
+		); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// beginEnrollment issues a new unconfirmed secret and answers with what an
+// authenticator app needs: the secret, the otpauth URI and a QR code of it.
+func beginEnrollment(c *gin.Context, kind string, id, schoolID int, account string) {
+	state, err := loadTwoFactor(kind, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+	if state.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	var issuer string
+	if err := database.DB.QueryRow("SELECT name FROM schools WHERE id = ?", schoolID).Scan(&issuer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch school"})
+		return
+	}
+
+	secret := utils.NewTOTPSecret()
+	_, err = database.DB.Exec(`
+		INSERT INTO two_factor (owner_kind, owner_id, secret) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE secret = VALUES(secret), last_used_step = 0`,
+		kind, id, secret,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to store 2FA secret for %s %d: %v", kind, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	uri := utils.OTPAuthURI(issuer, account, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, enrollmentQRSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_png":      "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// GetTwoFactorStatus tells the caller whether 2FA is on and how many unused
+// recovery codes they have left.
+func GetTwoFactorStatus(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	kind, _, _ := accountTable(user.Role)
+
+	state, err := loadTwoFactor(kind, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+	mandatory, err := twoFactorMandatory(kind, user.SchoolID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+	var remaining int
+	if err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM two_factor_recovery_codes WHERE owner_kind = ? AND owner_id = ? AND used_at IS NULL",
+		kind, user.ID,
+	).Scan(&remaining); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":             state.Enabled,
+		"pending":             state.Pending,
+		"required":            mandatory,
+		"recovery_codes_left": remaining,
+	})
+}
+
+// SetupTwoFactor starts enrollment for a signed-in user.
+func SetupTwoFactor(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	kind, _, _ := accountTable(user.Role)
+	beginEnrollment(c, kind, user.ID, user.SchoolID, user.FullName)
+}
+
+// EnableTwoFactor confirms enrollment with a code from the authenticator and
+// returns the recovery codes, which are not shown again.
+func EnableTwoFactor(c *gin.Context) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	kind, _, _ := accountTable(user.Role)
+	state, err := loadTwoFactor(kind, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+	if state.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !state.Pending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+
+	ok, err := useTOTPCode(kind, user.ID, state, input.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": secondFactorIncorrect})
+		return
+	}
+
+	codes, err := enableTwoFactor(kind, user.ID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to enable 2FA for %s %d: %v", kind, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, e.g. after some were
+// used. It needs a current authenticator code.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	kind, _, _ := accountTable(user.Role)
+	state, err := loadTwoFactor(kind, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+	if !state.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	ok, err := useTOTPCode(kind, user.ID, state, input.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": secondFactorIncorrect})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+	defer tx.Rollback()
+	codes, err := replaceRecoveryCodes(tx, kind, user.ID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to replace recovery codes for %s %d: %v", kind, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns 2FA off after re-checking the password and, once 2FA
+// is enabled, a current authenticator or recovery code, so a stolen session
+// and password alone can't remove it. Staff can't turn it off while their
+// school requires it.
+func DisableTwoFactor(c *gin.Context) {
+	var input struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	kind, table, column := accountTable(user.Role)
+	mandatory, err := twoFactorMandatory(kind, user.SchoolID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+	if mandatory {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your school requires two-factor authentication"})
+		return
+	}
+
+	var hash string
+	if err := database.DB.QueryRow("SELECT "+column+" FROM "+table+" WHERE id = ?", user.ID).Scan(&hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	if !utils.CheckPassword(input.Password, hash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	state, err := loadTwoFactor(kind, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+	if state.Enabled {
+		var ok bool
+		if input.RecoveryCode != "" {
+			ok, err = useRecoveryCode(kind, user.ID, input.RecoveryCode)
+		} else {
+			ok, err = useTOTPCode(kind, user.ID, state, input.Code)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": secondFactorIncorrect})
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	defer tx.Rollback()
+	for _, q := range []string{
+		"DELETE FROM two_factor WHERE owner_kind = ? AND owner_id = ?",
+		"DELETE FROM two_factor_recovery_codes WHERE owner_kind = ? AND owner_id = ?",
+	} {
+		if _, err := tx.Exec(q, kind, user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// GetSecuritySettings returns the school's sign-in policy.
+func GetSecuritySettings(c *gin.Context) {
+	var required bool
+	err := database.DB.QueryRow(
+		"SELECT require_admin_2fa FROM schools WHERE id = ?", middleware.CurrentUser(c).SchoolID,
+	).Scan(&required)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"require_admin_2fa": required})
+}
+
+// UpdateSecuritySettings lets the owner make 2FA compulsory for staff
+// accounts. Those not yet enrolled are walked through setup at next sign-in.
+func UpdateSecuritySettings(c *gin.Context) {
+	var input struct {
+		RequireAdmin2FA *bool `json:"require_admin_2fa"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.RequireAdmin2FA == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "require_admin_2fa is required"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	if _, err := database.DB.Exec(
+		"UPDATE schools SET require_admin_2fa = ? WHERE id = ?", *input.RequireAdmin2FA, user.SchoolID,
+	); err != nil {
+		log.Printf("[ERROR] Failed to update security settings for school %d: %v", user.SchoolID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Security settings updated", "require_admin_2fa": *input.RequireAdmin2FA})
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"school-backend/database"
+	"school-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lockCourse locks a course row for the rest of the transaction, so seats
+// are counted and taken one enrollment at a time. Seats are counted per term.
+func lockCourse(tx *sql.Tx, courseID, termID int) (capacity, enrolled int, err error) {
+	if err = tx.QueryRow("SELECT capacity FROM courses WHERE id = ? FOR UPDATE", courseID).Scan(&capacity); err != nil {
+		return
+	}
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM student_courses WHERE course_id = ? AND term_id = ?", courseID, termID,
+	).Scan(&enrolled)
+	return
+}
+
+// seatPlan follows a course's seats for a term the way enrollOrWaitlist
+// would, without writing anything or locking the course, for previews.
+type seatPlan struct {
+	capacity, enrolled, queued int
+	waiting                    map[int]bool
+}
+
+// planSeats reads the seats and active queue of each course for the term.
+func planSeats(q querier, courseIDs []int, termID int) (map[int]*seatPlan, error) {
+	plans := map[int]*seatPlan{}
+	for _, courseID := range courseIDs {
+		p := &seatPlan{}
+		if err := q.QueryRow(`
+			SELECT capacity,
+			       (SELECT COUNT(*) FROM student_courses WHERE course_id = c.id AND term_id = ?),
+			       (SELECT COUNT(*) FROM course_waitlist w JOIN students s ON w.student_id = s.id
+			        WHERE w.course_id = c.id AND w.term_id = ? AND s.status = ?)
+			FROM courses c WHERE id = ?`,
+			termID, termID, accountActive, courseID,
+		).Scan(&p.capacity, &p.enrolled, &p.queued); err != nil {
+			return nil, err
+		}
+		waiting, err := courseSet(q,
+			"SELECT student_id FROM course_waitlist WHERE course_id = ? AND term_id = ?", courseID, termID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.waiting = waiting
+		plans[courseID] = p
+	}
+	return plans, nil
+}
+
+// take gives the student a seat if enrollOrWaitlist would, and otherwise
+// queues them. It reports whether they got a seat.
+func (p *seatPlan) take(studentID int) bool {
+	if p.waiting[studentID] {
+		return false
+	}
+	if p.capacity == 0 || (p.enrolled < p.capacity && p.queued == 0) {
+		p.enrolled++
+		return true
+	}
+	p.queued++
+	p.waiting[studentID] = true
+	return false
+}
+
+// singleTerm resolves ?term_id= to one term, defaulting to the current one,
+// for figures that only make sense per term.
+func singleTerm(c *gin.Context) (int, bool) {
+	if c.Query("term_id") == "all" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "term_id must name a single term here"})
+		return 0, false
+	}
+	scope, ok := middleware.RequestedTerm(c)
+	return scope.ID, ok
+}
+
+// enrollOrWaitlist gives the student a seat in the course if one is free and
+// no active student is already queued for it, and otherwise puts them at the
+// back of the waitlist, for the given term. The change is recorded in the
+// history as made by the given user. It returns the waitlist position, or 0
+// if the student was enrolled.
+func enrollOrWaitlist(tx *sql.Tx, studentID, courseID, termID int, by middleware.Principal, note string) (int, error) {
+	capacity, enrolled, err := lockCourse(tx, courseID, termID)
+	if err != nil {
+		return 0, err
+	}
+	var queued int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM course_waitlist w
+		JOIN students s ON w.student_id = s.id
+		WHERE w.course_id = ? AND w.term_id = ? AND s.status = ?`,
+		courseID, termID, accountActive,
+	).Scan(&queued); err != nil {
+		return 0, err
+	}
+	if capacity == 0 || (enrolled < capacity && queued == 0) {
+		if _, err := tx.Exec(
+			"INSERT INTO student_courses (student_id, course_id, term_id) VALUES (?, ?, ?)", studentID, courseID, termID,
+		); err != nil {
+			return 0, err
+		}
+		return 0, recordEnrollment(tx, studentID, courseID, termID, enrollmentAdd, by, note)
+	}
+
+	var exists bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM course_waitlist WHERE course_id = ? AND student_id = ? AND term_id = ?)",
+		courseID, studentID, termID,
+	).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		if _, err := tx.Exec(
+			"INSERT INTO course_waitlist (course_id, student_id, term_id) VALUES (?, ?, ?)", courseID, studentID, termID,
+		); err != nil {
+			return 0, err
+		}
+		if err := recordEnrollment(tx, studentID, courseID, termID, enrollmentWaitlist, by, note); err != nil {
+			return 0, err
+		}
+	}
+	return waitlistPosition(tx, studentID, courseID, termID)
+}
+
+// waitlistPosition is the student's 1-based place in the course's queue for
+// the term, or 0 if they aren't on it. Inactive students ahead of them don't
+// count, since promotion passes them over.
+func waitlistPosition(q querier, studentID, courseID, termID int) (int, error) {
+	var position int
+	err := q.QueryRow(`
+		SELECT COUNT(*) FROM course_waitlist w
+		JOIN students s ON w.student_id = s.id
+		WHERE w.course_id = ? AND w.term_id = ? AND (s.status = ? OR w.student_id = ?)
+		  AND w.id <= (SELECT id FROM course_waitlist WHERE course_id = ? AND student_id = ? AND term_id = ?)`,
+		courseID, termID, accountActive, studentID, courseID, studentID, termID,
+	).Scan(&position)
+	return position, err
+}
+
+// promoteWaitlist fills free seats in a course from the front of its
+// waitlist and returns the students enrolled. Students who aren't active yet
+// keep their place but are passed over.
+func promoteWaitlist(tx *sql.Tx, courseID, termID int) ([]int, error) {
+	capacity, enrolled, err := lockCourse(tx, courseID, termID)
+	if err != nil {
+		return nil, err
+	}
+	promoted := []int{}
+	for capacity == 0 || enrolled < capacity {
+		var entryID, studentID int
+		err := tx.QueryRow(`
+			SELECT w.id, w.student_id
+			FROM course_waitlist w
+			JOIN students s ON w.student_id = s.id
+			WHERE w.course_id = ? AND w.term_id = ? AND s.status = ?
+			ORDER BY w.id ASC
+			LIMIT 1`,
+			courseID, termID, accountActive,
+		).Scan(&entryID, &studentID)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("DELETE FROM course_waitlist WHERE id = ?", entryID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO student_courses (student_id, course_id, term_id) VALUES (?, ?, ?)", studentID, courseID, termID,
+		); err != nil {
+			return nil, err
+		}
+		if err := recordEnrollment(tx, studentID, courseID, termID, enrollmentAdd, waitlistActor, "Promoted from the waitlist"); err != nil {
+			return nil, err
+		}
+		promoted = append(promoted, studentID)
+		enrolled++
+	}
+	return promoted, nil
+}
+
+// fillFreedSeats promotes waitlisted students into a course for a term in
+// its own transaction, for use after seats have been released.
+func fillFreedSeats(courseID, termID int) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("[ERROR] Failed to promote waitlist of course %d: %v", courseID, err)
+		return
+	}
+	defer tx.Rollback()
+	promoted, err := promoteWaitlist(tx, courseID, termID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to promote waitlist of course %d: %v", courseID, err)
+		return
+	}
+	if len(promoted) > 0 {
+		log.Printf("[INFO] Promoted students %v from the waitlist of course %d", promoted, courseID)
+	}
+}
+
+// fillSeatsFor runs promotion on every queue the student is waiting in, for
+// when they become active and can no longer be passed over.
+func fillSeatsFor(studentID int) {
+	rows, err := database.DB.Query(
+		"SELECT course_id, term_id FROM course_waitlist WHERE student_id = ?", studentID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch waitlist places of student %d: %v", studentID, err)
+		return
+	}
+	var queues [][2]int
+	for rows.Next() {
+		var q [2]int
+		if err := rows.Scan(&q[0], &q[1]); err != nil {
+			rows.Close()
+			log.Printf("[ERROR] Failed to read waitlist places of student %d: %v", studentID, err)
+			return
+		}
+		queues = append(queues, q)
+	}
+	rows.Close()
+	for _, q := range queues {
+		fillFreedSeats(q[0], q[1])
+	}
+}
+
+// dropCourse takes a student out of a course, records it and hands the seat
+// to the next student on the waitlist. It reports false if the student
+// wasn't enrolled.
+func dropCourse(tx *sql.Tx, studentID, courseID, termID int, by middleware.Principal, note string) (bool, []int, error) {
+	res, err := tx.Exec(
+		"DELETE FROM student_courses WHERE student_id = ? AND course_id = ? AND term_id = ?", studentID, courseID, termID,
+	)
+	if err != nil {
+		return false, nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil, nil
+	}
+	if err := recordEnrollment(tx, studentID, courseID, termID, enrollmentDrop, by, note); err != nil {
+		return false, nil, err
+	}
+	promoted, err := promoteWaitlist(tx, courseID, termID)
+	return true, promoted, err
+}
+
+type WaitlistEntry struct {
+	CourseID   int       `json:"course_id"`
+	TermID     int       `json:"term_id"`
+	CourseName string    `json:"course_name"`
+	CourseCode string    `json:"course_code"`
+	Position   int       `json:"position"`
+	Waiting    int       `json:"waiting"`
+	Capacity   int       `json:"capacity"`
+	Enrolled   int       `json:"enrolled"`
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+// GetStudentWaitlist lists the courses a student is queued for and where
+// they stand in each queue, for the current term unless ?term_id= says
+// otherwise.
+func GetStudentWaitlist(c *gin.Context) {
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+	scope, ok := middleware.RequestedTerm(c)
+	if !ok {
+		return
+	}
+	inTerm, termArgs := scope.Where("w.term_id")
+
+	rows, err := database.DB.Query(`
+		SELECT c.id, w.term_id, c.name, c.code, c.capacity, w.created_at,
+		       (SELECT COUNT(*) FROM course_waitlist x JOIN students xs ON x.student_id = xs.id
+		        WHERE x.course_id = w.course_id AND x.term_id = w.term_id AND x.id <= w.id AND (xs.status = ? OR x.id = w.id)),
+		       (SELECT COUNT(*) FROM course_waitlist x WHERE x.course_id = w.course_id AND x.term_id = w.term_id),
+		       (SELECT COUNT(*) FROM student_courses sc WHERE sc.course_id = w.course_id AND sc.term_id = w.term_id)
+		FROM course_waitlist w
+		JOIN courses c ON w.course_id = c.id
+		WHERE w.student_id = ? AND `+inTerm+`
+		ORDER BY w.created_at ASC`,
+		append([]interface{}{accountActive, studentID}, termArgs...)...,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist"})
+		return
+	}
+	defer rows.Close()
+
+	entries := []WaitlistEntry{}
+	for rows.Next() {
+		var e WaitlistEntry
+		if err := rows.Scan(&e.CourseID, &e.TermID, &e.CourseName, &e.CourseCode, &e.Capacity, &e.JoinedAt,
+			&e.Position, &e.Waiting, &e.Enrolled); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read waitlist"})
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// LeaveWaitlist takes a student out of a course's queue for the current
+// term, or the ?term_id= given. Everyone behind them moves up, and a free
+// seat is filled if there is one.
+func LeaveWaitlist(c *gin.Context) {
+	studentID, ok := middleware.SubjectID(c, middleware.RoleStudent)
+	if !ok {
+		return
+	}
+	courseID, err := strconv.Atoi(c.Param("course_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	termID, ok := singleTerm(c)
+	if !ok {
+		return
+	}
+
+	res, err := database.DB.Exec(
+		"DELETE FROM course_waitlist WHERE student_id = ? AND course_id = ? AND term_id = ?", studentID, courseID, termID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student is not on the waitlist for this course"})
+		return
+	}
+	fillFreedSeats(courseID, termID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from waitlist"})
+}
+
+// GetCourseWaitlist shows a course's queue for a term in promotion order.
+func GetCourseWaitlist(c *gin.Context) {
+	courseID := c.Param("id")
+	if !middleware.InSchool(c, middleware.Tenant(c).Course(courseID), "Course") {
+		return
+	}
+	termID, ok := singleTerm(c)
+	if !ok {
+		return
+	}
+
+	var capacity, enrolled int
+	if err := database.DB.QueryRow(`
+		SELECT capacity, (SELECT COUNT(*) FROM student_courses WHERE course_id = courses.id AND term_id = ?)
+		FROM courses WHERE id = ?`,
+		termID, courseID,
+	).Scan(&capacity, &enrolled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.fullname, s.username, s.registrationNumber, w.created_at
+		FROM course_waitlist w
+		JOIN students s ON w.student_id = s.id
+		WHERE w.course_id = ? AND w.term_id = ?
+		ORDER BY w.id ASC`,
+		courseID, termID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist"})
+		return
+	}
+	defer rows.Close()
+
+	type Entry struct {
+		Position           int       `json:"position"`
+		StudentID          int       `json:"student_id"`
+		FullName           string    `json:"fullname"`
+		Username           string    `json:"username"`
+		RegistrationNumber string    `json:"registrationNumber"`
+		JoinedAt           time.Time `json:"joined_at"`
+	}
+	entries := []Entry{}
+	for rows.Next() {
+		e := Entry{Position: len(entries) + 1}
+		if err := rows.Scan(&e.StudentID, &e.FullName, &e.Username, &e.RegistrationNumber, &e.JoinedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read waitlist"})
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"term_id":  termID,
+		"capacity": capacity,
+		"enrolled": enrolled,
+		"waitlist": entries,
+	})
+}
@@ -0,0 +1,124 @@
+// Package mail delivers transactional email through a pluggable Sender. The
+// log and file senders are meant for development; smtp is for production.
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"school-backend/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+type Sender interface {
+	Send(Message) error
+}
+
+var sender Sender = LogSender{}
+
+// Use replaces the sender, e.g. with a fake in tests.
+func Use(s Sender) {
+	sender = s
+}
+
+// Setup picks the sender named by config.App.MailDriver.
+func Setup() error {
+	switch config.App.MailDriver {
+	case "", "log":
+		Use(LogSender{})
+	case "file":
+		if err := os.MkdirAll(config.App.MailDir, 0o755); err != nil {
+			return fmt.Errorf("mail dir: %w", err)
+		}
+		Use(FileSender{Dir: config.App.MailDir})
+	case "smtp":
+		Use(SMTPSender{
+			Addr:     config.App.SMTPAddr,
+			Username: config.App.SMTPUsername,
+			Password: config.App.SMTPPassword,
+		})
+	default:
+		return fmt.Errorf("unknown mail driver %q", config.App.MailDriver)
+	}
+	return nil
+}
+
+// Send delivers m through the configured sender.
+func Send(m Message) error {
+	return sender.Send(m)
+}
+
+// render builds an RFC 5322 message.
+func render(m Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + config.App.MailFrom + "\r\n")
+	b.WriteString("To: " + headerValue(m.To) + "\r\n")
+	b.WriteString("Subject: " + headerValue(m.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue keeps stored values from injecting extra headers.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// LogSender writes messages to the server log instead of sending them.
+type LogSender struct{}
+
+func (LogSender) Send(m Message) error {
+	log.Printf("📧 Mail to %s: %s\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+// FileSender writes each message to its own .eml file in Dir.
+type FileSender struct {
+	Dir string
+}
+
+func (s FileSender) Send(m Message) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), safeFileName(m.To))
+	return os.WriteFile(filepath.Join(s.Dir, name), render(m), 0o600)
+}
+
+func safeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// SMTPSender relays through an SMTP server, authenticating when a username is set.
+type SMTPSender struct {
+	Addr     string // host:port
+	Username string
+	Password string
+}
+
+func (s SMTPSender) Send(m Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host := s.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, config.App.MailFrom, []string{m.To}, render(m))
+}
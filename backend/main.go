@@ -1,9 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"school-backend/config"
 	"school-backend/controllers"
 	"school-backend/database"
 	"school-backend/handlers"
+	"school-backend/mail"
+	"school-backend/middleware"
+	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -11,9 +16,37 @@ import (
 )
 
 func main() {
+	config.MustLoad()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		database.Connect()
+		runMigrateCommand(os.Args[2:])
+		return
+	}
+
+	r := setupRouter()
+
+
+	if err := mail.Setup(); err != nil {
+		panic("❌ Mail setup failed: " + err.Error())
+	}
+
+	database.Connect()
+	database.Migrate()
+
+	r.Run(fmt.Sprintf(":%d", config.App.Port))
+}
+
+// setupRouter registers every route with the middleware guarding it.
+func setupRouter() *gin.Engine {
 	r := gin.Default()
+	// only proxies we run may say who the client is; login throttling keys
+	// on the client IP
+	if err := r.SetTrustedProxies(config.App.TrustedProxies); err != nil {
+		panic("❌ Invalid trusted proxies: " + err.Error())
+	}
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://192.168.100.45:3000"},
+		AllowOrigins:     config.App.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
@@ -23,37 +56,151 @@ func main() {
 
 	r.POST("/register", handlers.Register)
 	r.POST("/login", handlers.Login)
-	r.GET("/me", handlers.GetCurrentUser)
 	r.GET("/logout", handlers.Logout)
-	r.POST("/teacher/:id/courses", handlers.AssignCoursesToTeacher)
-	r.GET("/teacher/:id/selectedcourses", handlers.GetTeacherCourses)
-	r.GET("/courses", handlers.GetAllCourses)
-	r.GET("/courses/department/:department", handlers.GetCoursesByDepartment)
-	r.POST("/student/:id/courses", handlers.AssignCoursesToStudent)
-	r.GET("/student/:id/courses", handlers.GetStudentCourses)
-	r.GET("/student/:id/department-courses", handlers.GetCoursesByStudentDepartment)
-	r.GET("/teacher/:id/courses-with-count", handlers.GetTeacherCoursesy)
-	r.GET("/:slug/teacher/:id/students", handlers.GetStudentsForTeacher)
-	r.GET("/:slug/teachers/detailed", controllers.GetAllTeachersDetailed)
-	r.GET("/:slug/students/detailed", controllers.GetAllStudentsDetailed)
-	r.POST("/cats", handlers.CreateCat)
-	r.PUT("/cats/:id", handlers.UpdateCat)
-	r.DELETE("/cats/:id", handlers.DeleteCat)
-	r.GET("/:slug/cats/teacher/:id", handlers.GetCatsByTeacher)
-	r.GET("/cats/student/:id", handlers.GetCatsForStudent)
-	r.POST("/teacher/:id/schedule", controllers.AddClassSchedule)
-	r.GET("/teacher/:id/schedule", controllers.GetTeacherSchedule)
-	r.GET("/student/:id/classes", handlers.GetStudentClasses)
-	r.DELETE("/teacher/:id/course/:courseId", handlers.DeleteAssignedCourse)
+	r.POST("/session/refresh", handlers.RefreshSession)
 	r.POST("/schoolregistration", handlers.RegisterSchool)
 	r.POST("/schoollogin", handlers.SchoolLogin)
-    r.POST("/schools/:slug/setup", handlers.SchoolSetupHandler)          
-    r.GET("/schools/:slug/setup", handlers.GetSchoolSetupHandler)          
+	r.POST("/finance/login", handlers.FinanceLogin)
+	r.POST("/password/forgot", handlers.ForgotPassword)
+	r.POST("/password/reset", handlers.ResetPassword)
+	r.POST("/login/2fa", handlers.TwoFactorLogin)
+	r.POST("/login/2fa/setup", handlers.TwoFactorLoginSetup)
+	r.GET("/invitations/:token", handlers.GetStaffInvitation)
+	r.POST("/invitations/accept", handlers.AcceptStaffInvitation)
+	r.GET("/schools/:slug/setup", handlers.GetSchoolSetupHandler)
+	r.GET("/ics/:token", handlers.ServeCalendarFeed)
 
-r.Static("/uploads", "./uploads")
+	// Sub-admins only reach the areas their role covers, and auditors only
+	// ever get the *View (GET) variants.
+	student := middleware.RequireRole(middleware.RoleStudent, middleware.RoleMainAdmin, middleware.RoleRegistrar)
+	studentView := middleware.RequireRole(middleware.RoleStudent, middleware.RoleMainAdmin, middleware.RoleRegistrar, middleware.RoleAcademicAdmin, middleware.RoleAuditor)
+	teacher := middleware.RequireRole(middleware.RoleTeacher, middleware.RoleMainAdmin, middleware.RoleAcademicAdmin)
+	teacherView := middleware.RequireRole(middleware.RoleTeacher, middleware.RoleMainAdmin, middleware.RoleAcademicAdmin, middleware.RoleRegistrar, middleware.RoleAuditor)
+	member := middleware.RequireRole(append([]string{middleware.RoleStudent, middleware.RoleTeacher}, middleware.StaffRoles...)...)
+	admin := middleware.RequireRole(middleware.RoleMainAdmin)
+	registrar := middleware.RequireRole(middleware.RoleMainAdmin, middleware.RoleRegistrar)
+	academic := middleware.RequireRole(middleware.RoleMainAdmin, middleware.RoleAcademicAdmin)
+	staffView := middleware.RequireRole(middleware.StaffRoles...)
+	school := middleware.RequireSchool()
+	subscriber := middleware.RequireRole(middleware.RoleStudent, middleware.RoleTeacher)
+	finance := middleware.RequireRole(middleware.RoleFinance, middleware.RoleMainAdmin)
+	financeView := middleware.RequireRole(middleware.RoleFinance, middleware.RoleMainAdmin, middleware.RoleAuditor)
+	enrolled := middleware.RequireRole(middleware.RoleStudent)
+	billed := middleware.RequireRole(middleware.RoleStudent, middleware.RoleFinance, middleware.RoleMainAdmin, middleware.RoleAuditor)
+	current := middleware.RequireCurrentStudent()
+	twoFactor := middleware.RequireRole(append([]string{middleware.RoleTeacher}, middleware.StaffRoles...)...)
 
+	auth := r.Group("/", middleware.RequireAuth())
+	auth.GET("/me", handlers.GetCurrentUser)
+	auth.POST("/me/password", handlers.ChangePassword)
+	auth.GET("/me/sessions", handlers.ListSessions)
+	auth.DELETE("/me/sessions/:id", handlers.RevokeSession)
+	auth.POST("/me/sessions/revoke-all", handlers.RevokeAllSessions)
+	auth.GET("/me/2fa", twoFactor, handlers.GetTwoFactorStatus)
+	auth.POST("/me/2fa/setup", twoFactor, handlers.SetupTwoFactor)
+	auth.POST("/me/2fa/enable", twoFactor, handlers.EnableTwoFactor)
+	auth.POST("/me/2fa/disable", twoFactor, handlers.DisableTwoFactor)
+	auth.POST("/me/2fa/recovery-codes", twoFactor, handlers.RegenerateRecoveryCodes)
+	auth.GET("/me/calendar", subscriber, handlers.GetCalendarFeed)
+	auth.POST("/me/calendar/rotate", subscriber, handlers.RotateCalendarFeed)
+	auth.POST("/teacher/:id/courses", teacher, handlers.AssignCoursesToTeacher)
+	auth.GET("/teacher/:id/selectedcourses", teacherView, handlers.GetTeacherCourses)
+	auth.GET("/courses", member, handlers.GetAllCourses)
+	auth.GET("/courses/department/:department", member, handlers.GetCoursesByDepartment)
+	auth.POST("/student/:id/courses", student, current, handlers.AssignCoursesToStudent)
+	auth.GET("/student/:id/courses", studentView, handlers.GetStudentCourses)
+	auth.DELETE("/student/:id/courses/:course_id", student, current, handlers.DropStudentCourse)
+	auth.GET("/student/:id/waitlist", studentView, handlers.GetStudentWaitlist)
+	auth.GET("/student/:id/enrollment-history", studentView, handlers.GetEnrollmentHistory)
+	auth.GET("/student/:id/enrollment-requests", studentView, handlers.GetStudentEnrollmentRequests)
+	auth.GET("/add-drop", member, handlers.GetAddDropWindow)
+	auth.GET("/terms", member, handlers.ListTerms)
+	auth.DELETE("/student/:id/waitlist/:course_id", student, current, handlers.LeaveWaitlist)
+	auth.GET("/student/:id/department-courses", studentView, handlers.GetCoursesByStudentDepartment)
+	auth.GET("/student/:id/eligibility", studentView, handlers.GetStudentEligibility)
+	auth.GET("/teacher/:id/courses-with-count", teacherView, handlers.GetTeacherCoursesy)
+	auth.GET("/:slug/teacher/:id/students", teacherView, school, handlers.GetStudentsForTeacher)
+	auth.GET("/:slug/teachers/detailed", staffView, school, controllers.GetAllTeachersDetailed)
+	auth.GET("/:slug/students/detailed", staffView, school, controllers.GetAllStudentsDetailed)
+	auth.POST("/cats", teacher, handlers.CreateCat)
+	auth.PUT("/cats/:id", teacher, handlers.UpdateCat)
+	auth.DELETE("/cats/:id", teacher, handlers.DeleteCat)
+	auth.GET("/cats/:id/marks", teacherView, handlers.GetCatMarks)
+	auth.PUT("/cats/:id/marks", teacher, handlers.SaveCatMarks)
+	auth.POST("/cats/:id/publish", teacher, handlers.PublishCatMarks)
+	auth.POST("/cats/:id/unpublish", teacher, handlers.UnpublishCatMarks)
+	auth.GET("/:slug/cats/teacher/:id", teacherView, school, handlers.GetCatsByTeacher)
+	auth.GET("/cats/student/:id", studentView, handlers.GetCatsForStudent)
+	auth.POST("/teacher/:id/schedule", teacher, controllers.AddClassSchedule)
+	auth.GET("/teacher/:id/schedule", teacherView, controllers.GetTeacherSchedule)
+	auth.PUT("/teacher/:id/schedule/:scheduleId", teacher, controllers.UpdateClassSchedule)
+	auth.POST("/attendance/sessions", teacher, handlers.OpenAttendanceSession)
+	auth.GET("/attendance/sessions/:id", teacherView, handlers.GetAttendanceSession)
+	auth.PUT("/attendance/sessions/:id/records", teacher, handlers.SaveAttendance)
+	auth.GET("/attendance/courses/:id", teacherView, handlers.GetCourseAttendance)
+	auth.GET("/attendance/sessions/:id/checkin-code", teacher, handlers.GetCheckinCode)
+	auth.GET("/attendance/sessions/:id/checkin-qr.png", teacher, handlers.GetCheckinQR)
+	auth.POST("/attendance/checkin", enrolled, current, handlers.CheckIn)
+	auth.DELETE("/teacher/:id/schedule/:scheduleId", teacher, controllers.DeleteClassSchedule)
+	auth.GET("/student/:id/classes", studentView, handlers.GetStudentClasses)
+	auth.GET("/student/:id/attendance", studentView, handlers.GetStudentAttendance)
+	auth.DELETE("/teacher/:id/course/:courseId", teacher, handlers.DeleteAssignedCourse)
+	auth.POST("/schools/:slug/setup", admin, school, handlers.SchoolSetupHandler)
+	auth.GET("/:slug/departments", staffView, school, handlers.ListDepartments)
+	auth.POST("/:slug/departments", academic, school, handlers.CreateDepartment)
+	auth.PUT("/:slug/departments/:id", academic, school, handlers.UpdateDepartment)
+	auth.DELETE("/:slug/departments/:id", academic, school, handlers.DeleteDepartment)
+	auth.GET("/:slug/courses", staffView, school, handlers.ListSchoolCourses)
+	auth.POST("/:slug/courses", academic, school, handlers.CreateCourse)
+	auth.PUT("/:slug/courses/:id", academic, school, handlers.UpdateCourse)
+	auth.DELETE("/:slug/courses/:id", academic, school, handlers.DeleteCourse)
+	auth.GET("/:slug/courses/:id/requirements", staffView, school, handlers.GetCourseRequirements)
+	auth.PUT("/:slug/courses/:id/requirements", academic, school, handlers.SetCourseRequirements)
+	auth.GET("/:slug/courses/:id/waitlist", staffView, school, handlers.GetCourseWaitlist)
+	auth.GET("/:slug/semesters", staffView, school, handlers.ListSemesters)
+	auth.POST("/:slug/semesters", academic, school, handlers.SaveSemester)
+	auth.POST("/:slug/semesters/:id/current", academic, school, handlers.SetCurrentTerm)
+	auth.GET("/:slug/academic-years", staffView, school, handlers.ListAcademicYears)
+	auth.POST("/:slug/academic-years", academic, school, handlers.SaveAcademicYear)
+	auth.POST("/:slug/academic-years/:id/current", academic, school, handlers.SetCurrentAcademicYear)
+	auth.GET("/:slug/promotion-rules", staffView, school, handlers.GetPromotionRules)
+	auth.PUT("/:slug/promotion-rules", registrar, school, handlers.UpdatePromotionRules)
+	auth.POST("/:slug/promotions", registrar, school, handlers.RunPromotions)
+	auth.GET("/:slug/promotions", staffView, school, handlers.ListPromotions)
+	auth.GET("/:slug/login-locks", registrar, school, handlers.ListLockedAccounts)
+	auth.POST("/:slug/login-locks/unlock", registrar, school, handlers.UnlockAccount)
+	auth.GET("/:slug/security", admin, school, handlers.GetSecuritySettings)
+	auth.PUT("/:slug/security", admin, school, handlers.UpdateSecuritySettings)
+	auth.GET("/:slug/registration", admin, school, handlers.GetRegistrationSettings)
+	auth.PUT("/:slug/registration", admin, school, handlers.UpdateRegistrationSettings)
+	auth.GET("/:slug/registrations", admin, school, handlers.ListPendingRegistrations)
+	auth.POST("/:slug/registrations/:role/:id/approve", admin, school, handlers.ApproveRegistration)
+	auth.POST("/:slug/registrations/:role/:id/reject", admin, school, handlers.RejectRegistration)
+	auth.POST("/:slug/students", registrar, school, handlers.CreateStudentAccount)
+	auth.POST("/:slug/teachers", registrar, school, handlers.CreateTeacherAccount)
+	auth.POST("/:slug/import/:role", registrar, school, handlers.ImportAccounts)
+	auth.POST("/:slug/enrollments/bulk", registrar, school, handlers.BulkEnroll)
+	auth.GET("/:slug/enrollments/overrides", staffView, school, handlers.ListEnrollmentOverrides)
+	auth.GET("/:slug/enrollment-requests", staffView, school, handlers.ListEnrollmentRequests)
+	auth.POST("/:slug/enrollment-requests/:id/approve", registrar, school, handlers.ApproveEnrollmentRequest)
+	auth.POST("/:slug/enrollment-requests/:id/reject", registrar, school, handlers.RejectEnrollmentRequest)
+	auth.GET("/:slug/staff", admin, school, handlers.ListStaff)
+	auth.PUT("/:slug/staff/:id/role", admin, school, handlers.UpdateStaffRole)
+	auth.DELETE("/:slug/staff/:id", admin, school, handlers.RemoveStaff)
+	auth.GET("/:slug/staff/invitations", admin, school, handlers.ListStaffInvitations)
+	auth.POST("/:slug/staff/invitations", admin, school, handlers.InviteStaff)
+	auth.DELETE("/:slug/staff/invitations/:id", admin, school, handlers.RevokeStaffInvitation)
+	auth.POST("/:slug/finance/accounts", admin, school, handlers.CreateFinanceAccount)
+	auth.GET("/:slug/finance/fee-structures", financeView, school, handlers.ListFeeStructures)
+	auth.POST("/:slug/finance/fee-structures", finance, school, handlers.CreateFeeStructure)
+	auth.DELETE("/:slug/finance/fee-structures/:id", finance, school, handlers.DeleteFeeStructure)
+	auth.POST("/:slug/finance/fee-structures/:id/invoices", finance, school, handlers.GenerateFeeInvoices)
+	auth.POST("/:slug/finance/payments", finance, school, handlers.RecordPayment)
+	auth.GET("/:slug/finance/students/:id/statement", financeView, school, handlers.GetStudentStatement)
+	auth.GET("/:slug/finance/arrears", financeView, school, handlers.GetArrearsReport)
+	auth.GET("/student/:id/statement", billed, handlers.GetStudentStatement)
 
-	database.Connect()
+	r.Static("/uploads", config.App.UploadDir)
 
-	r.Run(":8080")
+	return r
 }
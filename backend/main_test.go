@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"school-backend/config"
+	"school-backend/database"
+	"school-backend/middleware"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// These tests need a MySQL database they may migrate and write to, named by
+// TEST_DATABASE_DSN (e.g. "root@tcp(127.0.0.1:3306)/school_test?parseTime=true").
+// They are skipped without one.
+
+// school is the rows one tenant owns in the tests.
+type school struct {
+	id, adminID, studentID, teacherID, courseID, catID, scheduleID int
+	slug                                                           string
+}
+
+var router *gin.Engine
+
+func TestMain(m *testing.M) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		fmt.Println("TEST_DATABASE_DSN not set; skipping database tests")
+		os.Exit(m.Run())
+	}
+
+	gin.SetMode(gin.TestMode)
+	config.App = &config.Config{
+		DatabaseDSN: dsn,
+		JWTSecret:   "test-secret-for-tenant-isolation",
+		TokenTTL:    time.Hour,
+		RefreshTTL:  time.Hour,
+		UploadDir:   os.TempDir(),
+		CORSOrigins: []string{"http://localhost"},
+	}
+	database.Connect()
+	if _, err := database.MigrateUp(); err != nil {
+		fmt.Println("migrate:", err)
+		os.Exit(1)
+	}
+	router = setupRouter()
+	os.Exit(m.Run())
+}
+
+func needDB(t *testing.T) {
+	t.Helper()
+	if router == nil {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+}
+
+func insert(t *testing.T, query string, args ...interface{}) int {
+	t.Helper()
+	res, err := database.DB.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id)
+}
+
+// seedSchool creates a school with an admin, a student enrolled in a course,
+// a teacher of that course, a CAT and a timetable slot.
+func seedSchool(t *testing.T, name string) school {
+	t.Helper()
+	s := school{slug: fmt.Sprintf("%s-%d", name, time.Now().UnixNano())}
+	s.id = insert(t, "INSERT INTO schools (name, slug) VALUES (?, ?)", name, s.slug)
+	s.adminID = insert(t, "INSERT INTO users (school_id, email, password_hash, role) VALUES (?, ?, '', ?)",
+		s.id, s.slug+"@example.com", middleware.RoleMainAdmin)
+	s.studentID = insert(t, "INSERT INTO students (school_id, fullname, username, password) VALUES (?, 'Student', 'student', '')", s.id)
+	s.teacherID = insert(t, "INSERT INTO teachers (school_id, fullname, username, password) VALUES (?, 'Teacher', 'teacher', '')", s.id)
+	s.courseID = insert(t, "INSERT INTO courses (school_id, name, code) VALUES (?, 'Course', ?)", s.id, "C-"+name)
+	insert(t, "INSERT INTO teacher_courses (teacher_id, course_id) VALUES (?, ?)", s.teacherID, s.courseID)
+	insert(t, "INSERT INTO student_courses (student_id, course_id) VALUES (?, ?)", s.studentID, s.courseID)
+	s.catID = insert(t, "INSERT INTO cats (course_id, teacher_id, cat_datetime) VALUES (?, ?, '2030-01-07 09:00:00')",
+		s.courseID, s.teacherID)
+	s.scheduleID = insert(t, `
+		INSERT INTO class_schedules (school_id, teacher_id, course_id, day_of_week, start_time, end_time, venue, semester)
+		VALUES (?, ?, ?, 'Monday', '09:00:00', '10:00:00', 'Room 1', 'S1')`,
+		s.id, s.teacherID, s.courseID)
+	return s
+}
+
+// signIn opens a session for the account and returns its access token.
+func signIn(t *testing.T, s school, id int, kind, role string) string {
+	t.Helper()
+	sid := insert(t, `
+		INSERT INTO sessions (school_id, owner_kind, owner_id, role, db_slug, refresh_hash, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, NOW(), DATE_ADD(NOW(), INTERVAL 1 HOUR))`,
+		s.id, kind, id, role, s.slug, utils.HashToken(utils.RandomToken(32)))
+	return utils.GenerateJWT(id, s.id, sid, "Tester", "", s.slug, role)
+}
+
+func call(t *testing.T, token, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func stillThere(t *testing.T, table string, id int) {
+	t.Helper()
+	var n int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE id = ?", id).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%s %d was changed by another school", table, id)
+	}
+}
+
+func TestTenantChecks(t *testing.T) {
+	needDB(t)
+	a, b := seedSchool(t, "alpha"), seedSchool(t, "beta")
+	tenant := database.Tenant(a.id)
+
+	checks := []struct {
+		kind       string
+		check      func(interface{}) error
+		own, other int
+	}{
+		{"student", tenant.Student, a.studentID, b.studentID},
+		{"teacher", tenant.Teacher, a.teacherID, b.teacherID},
+		{"course", tenant.Course, a.courseID, b.courseID},
+		{"cat", tenant.Cat, a.catID, b.catID},
+		{"schedule", tenant.Schedule, a.scheduleID, b.scheduleID},
+	}
+	for _, c := range checks {
+		if err := c.check(c.own); err != nil {
+			t.Errorf("own %s: %v", c.kind, err)
+		}
+		if err := c.check(c.other); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("other school's %s: got %v, want ErrNotFound", c.kind, err)
+		}
+	}
+}
+
+func TestAdminCannotReachAnotherSchool(t *testing.T) {
+	needDB(t)
+	a, b := seedSchool(t, "alpha"), seedSchool(t, "beta")
+	token := signIn(t, a, a.adminID, "user", middleware.RoleMainAdmin)
+
+	// the same routes answer for the admin's own school, so a 404 below
+	// comes from the tenant check rather than a missing route
+	for _, path := range []string{
+		fmt.Sprintf("/student/%d/courses", a.studentID),
+		fmt.Sprintf("/cats/student/%d", a.studentID),
+		fmt.Sprintf("/cats/%d/marks", a.catID),
+		"/" + a.slug + "/courses",
+		fmt.Sprintf("/teacher/%d/schedule", a.teacherID),
+	} {
+		if w := call(t, token, "GET", path, ""); w.Code != http.StatusOK {
+			t.Errorf("GET %s: got %d %s, want 200", path, w.Code, w.Body.String())
+		}
+	}
+
+	cases := []struct {
+		method, path, body string
+	}{
+		{"GET", fmt.Sprintf("/student/%d/courses", b.studentID), ""},
+		{"POST", fmt.Sprintf("/student/%d/courses", b.studentID), fmt.Sprintf(`{"course_ids":[%d]}`, a.courseID)},
+		{"POST", fmt.Sprintf("/student/%d/courses", a.studentID), fmt.Sprintf(`{"course_ids":[%d]}`, b.courseID)},
+		{"GET", fmt.Sprintf("/cats/student/%d", b.studentID), ""},
+		{"PUT", fmt.Sprintf("/cats/%d", b.catID), `{"new_datetime":"2030-02-04T09:00:00Z"}`},
+		{"DELETE", fmt.Sprintf("/cats/%d", b.catID), ""},
+		{"GET", fmt.Sprintf("/cats/%d/marks", b.catID), ""},
+		{"GET", "/" + b.slug + "/courses", ""},
+		{"PUT", fmt.Sprintf("/%s/courses/%d", a.slug, b.courseID), `{"name":"Taken","code":"TAKEN"}`},
+		{"DELETE", fmt.Sprintf("/%s/courses/%d", a.slug, b.courseID), ""},
+		{"GET", fmt.Sprintf("/teacher/%d/schedule", b.teacherID), ""},
+		{"PUT", fmt.Sprintf("/teacher/%d/schedule/%d", b.teacherID, b.scheduleID),
+			fmt.Sprintf(`{"course_id":%d,"day_of_week":"Tuesday","start_time":"09:00","end_time":"10:00","venue":"X","semester":"S1"}`, b.courseID)},
+		{"PUT", fmt.Sprintf("/teacher/%d/schedule/%d", a.teacherID, b.scheduleID),
+			fmt.Sprintf(`{"course_id":%d,"day_of_week":"Tuesday","start_time":"09:00","end_time":"10:00","venue":"X","semester":"S1"}`, a.courseID)},
+		{"DELETE", fmt.Sprintf("/teacher/%d/schedule/%d", b.teacherID, b.scheduleID), ""},
+		{"DELETE", fmt.Sprintf("/teacher/%d/schedule/%d", a.teacherID, b.scheduleID), ""},
+	}
+	for _, tc := range cases {
+		if w := call(t, token, tc.method, tc.path, tc.body); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: got %d %s, want 404", tc.method, tc.path, w.Code, w.Body.String())
+		}
+	}
+
+	stillThere(t, "cats", b.catID)
+	stillThere(t, "class_schedules", b.scheduleID)
+	var name string
+	if err := database.DB.QueryRow("SELECT name FROM courses WHERE id = ?", b.courseID).Scan(&name); err != nil || name != "Course" {
+		t.Errorf("course %d was changed by another school: %q %v", b.courseID, name, err)
+	}
+	var enrolled int
+	database.DB.QueryRow(
+		"SELECT COUNT(*) FROM student_courses WHERE (student_id = ? AND course_id = ?) OR (student_id = ? AND course_id = ?)",
+		b.studentID, a.courseID, a.studentID, b.courseID,
+	).Scan(&enrolled)
+	if enrolled != 0 {
+		t.Errorf("enrollments crossed schools: %d", enrolled)
+	}
+}
+
+func TestAllCoursesListsOwnSchoolOnly(t *testing.T) {
+	needDB(t)
+	a, b := seedSchool(t, "alpha"), seedSchool(t, "beta")
+	token := signIn(t, a, a.studentID, "student", middleware.RoleStudent)
+
+	w := call(t, token, "GET", "/courses", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /courses: got %d", w.Code)
+	}
+	var courses []struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &courses); err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int]bool{}
+	for _, c := range courses {
+		seen[c.ID] = true
+	}
+	if !seen[a.courseID] || seen[b.courseID] {
+		t.Errorf("GET /courses returned %v, want course %d and not %d", courses, a.courseID, b.courseID)
+	}
+}
+
+func TestStudentCannotReachAnotherStudent(t *testing.T) {
+	needDB(t)
+	a, b := seedSchool(t, "alpha"), seedSchool(t, "beta")
+	token := signIn(t, a, a.studentID, "student", middleware.RoleStudent)
+
+	for _, path := range []string{
+		fmt.Sprintf("/student/%d/courses", b.studentID),
+		fmt.Sprintf("/cats/student/%d", b.studentID),
+	} {
+		if w := call(t, token, "GET", path, ""); w.Code != http.StatusForbidden {
+			t.Errorf("GET %s: got %d, want 403", path, w.Code)
+		}
+	}
+	if w := call(t, token, "GET", "/"+b.slug+"/courses", ""); w.Code != http.StatusForbidden && w.Code != http.StatusNotFound {
+		t.Errorf("GET /%s/courses: got %d, want 403 or 404", b.slug, w.Code)
+	}
+}
+
+func TestTeacherCreatesCatsOnlyForOwnCourses(t *testing.T) {
+	needDB(t)
+	a := seedSchool(t, "alpha")
+	otherID := insert(t, "INSERT INTO teachers (school_id, fullname, username, password) VALUES (?, 'Other', 'other', '')", a.id)
+	body := fmt.Sprintf(`{"course_id":%d,"cat_datetime":"2030-01-14T09:00:00Z","max_score":20}`, a.courseID)
+
+	other := signIn(t, a, otherID, "teacher", middleware.RoleTeacher)
+	if w := call(t, other, "POST", "/cats", body); w.Code != http.StatusForbidden {
+		t.Errorf("teacher of another course: got %d %s, want 403", w.Code, w.Body.String())
+	}
+	own := signIn(t, a, a.teacherID, "teacher", middleware.RoleTeacher)
+	if w := call(t, own, "POST", "/cats", body); w.Code != http.StatusOK {
+		t.Errorf("course's teacher: got %d %s, want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestDeleteCourseKeepsEnrollmentHistory(t *testing.T) {
+	needDB(t)
+	a := seedSchool(t, "alpha")
+	admin := signIn(t, a, a.adminID, "user", middleware.RoleMainAdmin)
+
+	dropped := insert(t, "INSERT INTO courses (school_id, name, code) VALUES (?, 'Dropped', ?)", a.id, "D-"+a.slug)
+	insert(t, "INSERT INTO enrollment_history (student_id, course_id, action, actor_role) VALUES (?, ?, 'drop', 'student')",
+		a.studentID, dropped)
+	if w := call(t, admin, "DELETE", fmt.Sprintf("/%s/courses/%d", a.slug, dropped), ""); w.Code != http.StatusConflict {
+		t.Errorf("course with history: got %d %s, want 409", w.Code, w.Body.String())
+	}
+	stillThere(t, "courses", dropped)
+
+	unused := insert(t, "INSERT INTO courses (school_id, name, code) VALUES (?, 'Unused', ?)", a.id, "U-"+a.slug)
+	if w := call(t, admin, "DELETE", fmt.Sprintf("/%s/courses/%d", a.slug, unused), ""); w.Code != http.StatusOK {
+		t.Errorf("unused course: got %d %s, want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestLowerMaxScoreOnlyChecksMarksNotReplaced(t *testing.T) {
+	needDB(t)
+	a := seedSchool(t, "alpha")
+	token := signIn(t, a, a.teacherID, "teacher", middleware.RoleTeacher)
+	insert(t, "INSERT INTO cat_marks (cat_id, student_id, score, recorded_by) VALUES (?, ?, 80, ?)",
+		a.catID, a.studentID, a.teacherID)
+	path := fmt.Sprintf("/cats/%d/marks", a.catID)
+
+	if w := call(t, token, "PUT", path, `{"max_score":50,"marks":[]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("stored mark over new max: got %d %s, want 400", w.Code, w.Body.String())
+	}
+	body := fmt.Sprintf(`{"max_score":50,"marks":[{"student_id":%d,"score":40}]}`, a.studentID)
+	if w := call(t, token, "PUT", path, body); w.Code != http.StatusOK {
+		t.Errorf("mark replaced in the same request: got %d %s, want 200", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"school-backend/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountGraduated is the status of a student who has finished their final
+// year. Alumni can still sign in and read their records.
+const AccountGraduated = "graduated"
+
+// RequireCurrentStudent keeps alumni out of the features only current
+// students use, such as enrolling and checking in. It applies to the student
+// the route acts on: the caller for a student, the /student/:id path for
+// staff. It must run after RequireAuth.
+func RequireCurrentStudent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := CurrentUser(c)
+		id := p.ID
+		if p.Role != RoleStudent {
+			n, err := strconv.Atoi(c.Param("id"))
+			if err != nil {
+				// no student to check; the handler reports a bad id
+				c.Next()
+				return
+			}
+			id = n
+		}
+
+		var status string
+		err := database.DB.QueryRow(
+			"SELECT status FROM students WHERE id = ? AND school_id = ?", id, p.SchoolID,
+		).Scan(&status)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to fetch status of student %d: %v", id, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch student"})
+			return
+		}
+		if status == AccountGraduated {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":  "This student has graduated; alumni can only view their records",
+				"status": status,
+			})
+			return
+		}
+		c.Next()
+	}
+}
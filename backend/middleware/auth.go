@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"school-backend/database"
+	"school-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RoleStudent   = "student"
+	RoleTeacher   = "teacher"
+	RoleMainAdmin = "main-admin"
+	RoleFinance   = "finance"
+	// Staff the main admin invites. Registrars keep student and teacher
+	// records, academic admins the catalog and timetable, and auditors can
+	// read what the others can but change nothing.
+	RoleRegistrar     = "registrar"
+	RoleAcademicAdmin = "academic-admin"
+	RoleAuditor       = "auditor"
+)
+
+// StaffRoles are the roles stored in users, which sign in by email.
+var StaffRoles = []string{RoleMainAdmin, RoleRegistrar, RoleAcademicAdmin, RoleFinance, RoleAuditor}
+
+// Principal is the authenticated caller, taken from the session JWT.
+type Principal struct {
+	ID         int    `json:"id"`
+	SchoolID   int    `json:"schoolID"`
+	Role       string `json:"role"`
+	FullName   string `json:"fullname"`
+	Department string `json:"department"`
+	DBSlug     string `json:"dbSlug"`
+	SessionID  int    `json:"-"`
+}
+
+const principalKey = "principal"
+
+// RequireAuth verifies the session token (cookie or bearer header) and stores
+// the caller in the context. Requests without a valid token are rejected.
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := sessionToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No session"})
+			return
+		}
+
+		id, schoolID, sid, role, fullname, department, dbSlug, err := utils.VerifyJWT(token)
+		if err != nil {
+			log.Printf("[ERROR] JWT verification failed: %v\n", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid session"})
+			return
+		}
+
+		var active bool
+		err = database.DB.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM sessions WHERE id = ? AND revoked_at IS NULL AND expires_at > NOW())", sid,
+		).Scan(&active)
+		if err != nil {
+			log.Printf("[ERROR] Session lookup failed: %v\n", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check session"})
+			return
+		}
+		if !active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			return
+		}
+
+		c.Set(principalKey, Principal{
+			ID:         id,
+			SchoolID:   schoolID,
+			Role:       role,
+			FullName:   fullname,
+			Department: department,
+			DBSlug:     dbSlug,
+			SessionID:  sid,
+		})
+		c.Next()
+	}
+}
+
+// RequireRole only lets the listed roles through. It must run after RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := principalFrom(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No session"})
+			return
+		}
+		for _, role := range roles {
+			if p.Role == role {
+				c.Next()
+				return
+			}
+		}
+		log.Printf("[WARN] role %s denied on %s %s", p.Role, c.Request.Method, c.FullPath())
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+	}
+}
+
+// CurrentUser returns the caller stored by RequireAuth.
+func CurrentUser(c *gin.Context) Principal {
+	p, _ := principalFrom(c)
+	return p
+}
+
+// SubjectID resolves which account a /student/:id or /teacher/:id route acts
+// on. A caller with the given role always acts on itself, and a path id naming
+// someone else is refused. Admins act on the id from the path, which must
+// belong to their school.
+func SubjectID(c *gin.Context, role string) (int, bool) {
+	p := CurrentUser(c)
+	param := c.Param("id")
+
+	if p.Role == role {
+		if param != "" && param != strconv.Itoa(p.ID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return 0, false
+		}
+		return p.ID, true
+	}
+
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + role + " ID"})
+		return 0, false
+	}
+
+	tenant := Tenant(c)
+	check, label := tenant.Teacher, "Teacher"
+	if role == RoleStudent {
+		check, label = tenant.Student, "Student"
+	}
+	if !InSchool(c, check(id), label) {
+		return 0, false
+	}
+	return id, true
+}
+
+func principalFrom(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
+
+func sessionToken(c *gin.Context) string {
+	if token, err := c.Cookie("session_token"); err == nil && token != "" {
+		return token
+	}
+	header := c.GetHeader("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return ""
+}
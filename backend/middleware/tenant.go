@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"school-backend/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant returns the scope of the caller's school.
+func Tenant(c *gin.Context) database.Tenant {
+	return database.Tenant(CurrentUser(c).SchoolID)
+}
+
+// RequireSchool rejects /:slug routes that name a school other than the
+// caller's. The school is reported as missing rather than forbidden so other
+// tenants can't be probed.
+func RequireSchool() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param("slug") != CurrentUser(c).DBSlug {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "School not found"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// InSchool writes the response for a failed tenant check and reports whether
+// the handler may go on. what names the row in the 404 message.
+func InSchool(c *gin.Context, err error, what string) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": what + " not found"})
+		return false
+	}
+	log.Printf("[ERROR] tenant check failed: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + what})
+	return false
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"school-backend/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve runs handlers for method path as caller and returns the response.
+func serve(caller Principal, route, method, path string, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	r := gin.New()
+	chain := append([]gin.HandlerFunc{func(c *gin.Context) { c.Set(principalKey, caller) }}, handlers...)
+	r.Handle(method, route, chain...)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func ok(c *gin.Context) { c.Status(http.StatusOK) }
+
+func TestRequireSchool(t *testing.T) {
+	admin := Principal{ID: 1, SchoolID: 1, Role: RoleMainAdmin, DBSlug: "school-a"}
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		if w := serve(admin, "/:slug/courses", method, "/school-a/courses", RequireSchool(), ok); w.Code != http.StatusOK {
+			t.Errorf("%s own school: got %d, want 200", method, w.Code)
+		}
+		if w := serve(admin, "/:slug/courses", method, "/school-b/courses", RequireSchool(), ok); w.Code != http.StatusNotFound {
+			t.Errorf("%s other school: got %d, want 404", method, w.Code)
+		}
+	}
+}
+
+func TestInSchool(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+		pass bool
+	}{
+		{"owned", nil, http.StatusOK, true},
+		{"other school", database.ErrNotFound, http.StatusNotFound, false},
+		{"wrapped not found", errors.Join(errors.New("check"), database.ErrNotFound), http.StatusNotFound, false},
+		{"lookup failed", errors.New("connection refused"), http.StatusInternalServerError, false},
+	}
+	for _, tc := range cases {
+		var passed bool
+		w := serve(Principal{SchoolID: 1}, "/x", http.MethodGet, "/x", func(c *gin.Context) {
+			if passed = InSchool(c, tc.err, "Course"); passed {
+				c.Status(http.StatusOK)
+			}
+		})
+		if passed != tc.pass || w.Code != tc.want {
+			t.Errorf("%s: got (%t, %d), want (%t, %d)", tc.name, passed, w.Code, tc.pass, tc.want)
+		}
+	}
+}
+
+func TestSubjectIDStudentActsOnSelf(t *testing.T) {
+	student := Principal{ID: 7, SchoolID: 1, Role: RoleStudent}
+	handler := func(c *gin.Context) {
+		if id, ok := SubjectID(c, RoleStudent); ok {
+			c.JSON(http.StatusOK, id)
+		}
+	}
+
+	if w := serve(student, "/student/:id/courses", http.MethodGet, "/student/7/courses", handler); w.Code != http.StatusOK {
+		t.Errorf("own id: got %d, want 200", w.Code)
+	}
+	if w := serve(student, "/student/:id/courses", http.MethodGet, "/student/8/courses", handler); w.Code != http.StatusForbidden {
+		t.Errorf("other id: got %d, want 403", w.Code)
+	}
+
+	admin := Principal{ID: 1, SchoolID: 1, Role: RoleMainAdmin}
+	if w := serve(admin, "/student/:id/courses", http.MethodGet, "/student/abc/courses", handler); w.Code != http.StatusBadRequest {
+		t.Errorf("bad id: got %d, want 400", w.Code)
+	}
+}
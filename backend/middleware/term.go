@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"school-backend/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TermScope limits a listing to one term. All is set for ?term_id=all, and
+// when the school has no current term so nothing is hidden.
+type TermScope struct {
+	ID  int
+	All bool
+}
+
+// Where returns a condition on column and its arguments, for appending to a
+// WHERE clause.
+func (s TermScope) Where(column string) (string, []interface{}) {
+	return "(? OR " + column + " = ?)", []interface{}{s.All, s.ID}
+}
+
+// RequestedTerm reads ?term_id=, which defaults to the school's current term
+// and may be "all". It writes the error response itself.
+func RequestedTerm(c *gin.Context) (TermScope, bool) {
+	schoolID := CurrentUser(c).SchoolID
+	switch v := c.Query("term_id"); v {
+	case "all":
+		return TermScope{All: true}, true
+	case "":
+		id, err := database.CurrentTermID(database.DB, schoolID)
+		if err != nil {
+			log.Printf("[ERROR] Failed to fetch current term of school %d: %v", schoolID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch current term"})
+			return TermScope{}, false
+		}
+		return TermScope{ID: id, All: id == 0}, true
+	default:
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "term_id must be a term id or all"})
+			return TermScope{}, false
+		}
+		if !InSchool(c, Tenant(c).Term(id), "Term") {
+			return TermScope{}, false
+		}
+		return TermScope{ID: id}, true
+	}
+}
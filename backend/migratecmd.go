@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"school-backend/database"
+	"strconv"
+)
+
+// runMigrateCommand handles `school-backend migrate [up | down [n] | status]`.
+func runMigrateCommand(args []string) {
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		n, err := database.MigrateUp()
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("✅ %d migration(s) applied\n", n)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fail(fmt.Errorf("invalid step count %q", args[1]))
+			}
+		}
+		n, err := database.MigrateDown(steps)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("✅ %d migration(s) rolled back\n", n)
+
+	case "status":
+		states, err := database.MigrationStatus()
+		if err != nil {
+			fail(err)
+		}
+		for _, s := range states {
+			mark := "pending"
+			if s.Applied {
+				mark = "applied"
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, mark)
+		}
+
+	default:
+		fail(fmt.Errorf("unknown migrate command %q (want up, down [n] or status)", cmd))
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "❌", err)
+	os.Exit(1)
+}
@@ -14,5 +14,6 @@ type ClassSchedule struct {
 	SchoolID   int    ` json:"school_id"`
 	Venue     string    `json:"venue"`
 	Semester  string    `json:"semester"`
+	TermID    int       `json:"term_id"`
 	CreatedAt time.Time
 }
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type Department struct {
+	ID        uint      `json:"id"`
+	SchoolID  int       `json:"school_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Course struct {
+	ID             uint      `json:"id"`
+	SchoolID       int       `json:"school_id"`
+	Name           string    `json:"name"`
+	Code           string    `json:"code"`
+	DepartmentID   *int      `json:"department_id"`
+	DepartmentName string    `json:"department"`
+	CreditUnits    int       `json:"credit_units"`
+	Description    string    `json:"description"`
+	Active         bool      `json:"active"`
+	Capacity       int       `json:"capacity"` // 0 is unlimited
+	Enrolled       int       `json:"enrolled"`
+	Waitlisted     int       `json:"waitlisted"`
+	CreatedAt      time.Time `json:"created_at"`
+}
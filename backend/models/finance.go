@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type FeeStructure struct {
+	ID           uint      `json:"id"`
+	SchoolID     int       `json:"school_id"`
+	DepartmentID int       `json:"department_id"`
+	Department   string    `json:"department"`
+	Year         string    `json:"year"`
+	Semester     string    `json:"semester"`
+	Name         string    `json:"name"`
+	Amount       float64   `json:"amount"`
+	DueDate      string    `json:"due_date"` // "2006-01-02"
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Invoice struct {
+	ID             uint    `json:"id"`
+	StudentID      int     `json:"student_id"`
+	FeeStructureID int     `json:"fee_structure_id"`
+	Description    string  `json:"description"`
+	Amount         float64 `json:"amount"`
+	DueDate        string  `json:"due_date"`
+}
+
+type Payment struct {
+	ID         uint    `json:"id"`
+	StudentID  int     `json:"student_id"`
+	InvoiceID  *int    `json:"invoice_id"`
+	Amount     float64 `json:"amount"`
+	Method     string  `json:"method"`
+	Reference  string  `json:"reference"`
+	PaidOn     string  `json:"paid_on"`
+	RecordedBy int     `json:"recorded_by"`
+}
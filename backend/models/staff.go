@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// StaffMember is a users row: the school owner or invited staff.
+type StaffMember struct {
+	ID          int       `json:"id"`
+	Email       string    `json:"email"`
+	FullName    string    `json:"fullname"`
+	Role        string    `json:"role"`
+	PhoneNumber string    `json:"phonenumber"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type StaffInvitation struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	FullName  string    `json:"fullname"`
+	Role      string    `json:"role"`
+	InvitedBy int       `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
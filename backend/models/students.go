@@ -7,6 +7,7 @@ import(
 type Student struct {
 	ID                uint   `json:"id" gorm:"primaryKey"`
 	Username          string `json:"username"`
+	Email             string `json:"email"`
 	Password          string `json:"password"` // Store hashed version
 	Role              string `json:"role"`
 	FullName          string `json:"fullname"`
@@ -7,6 +7,7 @@ type Teacher struct {
 	ID       uint   `json:"id"`
 	FullName string `json:"fullname"`
 	Username string `json:"username"`
+	Email    string `json:"email"`
 	Password string `json:"password"`
 	Subject  string `json:"subject"`
     Age      int     `json:"age"`
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCheckinInvalid = errors.New("invalid check-in code")
+	ErrCheckinExpired = errors.New("check-in code has expired")
+)
+
+// CheckinCode is what a class check-in QR code carries: the schedule slot and
+// date it is for and when it stops being accepted.
+type CheckinCode struct {
+	ScheduleID int
+	Date       string // "2006-01-02"
+	Expires    time.Time
+}
+
+// SignCheckinCode encodes c as "<payload>.<mac>", both base64url, signed with
+// the JWT secret so it can be checked without storing it.
+func SignCheckinCode(c CheckinCode) string {
+	payload := fmt.Sprintf("%d|%s|%d", c.ScheduleID, c.Date, c.Expires.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + checkinMAC(payload)
+}
+
+// ParseCheckinCode verifies the signature and expiry of a scanned code.
+func ParseCheckinCode(code string) (CheckinCode, error) {
+	var c CheckinCode
+	encoded, mac, ok := strings.Cut(strings.TrimSpace(code), ".")
+	if !ok {
+		return c, ErrCheckinInvalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return c, ErrCheckinInvalid
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(mac), []byte(checkinMAC(payload))) {
+		return c, ErrCheckinInvalid
+	}
+
+	var expires int64
+	parts := strings.Split(payload, "|")
+	if len(parts) != 3 {
+		return c, ErrCheckinInvalid
+	}
+	if _, err := fmt.Sscanf(parts[0]+" "+parts[2], "%d %d", &c.ScheduleID, &expires); err != nil {
+		return c, ErrCheckinInvalid
+	}
+	c.Date = parts[1]
+	c.Expires = time.Unix(expires, 0)
+	if time.Now().After(c.Expires) {
+		return c, ErrCheckinExpired
+	}
+	return c, nil
+}
+
+func checkinMAC(payload string) string {
+	h := hmac.New(sha256.New, append([]byte("checkin:"), jwtKey()...))
+	h.Write([]byte(payload))
+	// 16 bytes is plenty for a code that lives a few minutes and keeps the QR small
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+}
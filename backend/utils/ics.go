@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"strings"
+	"time"
+)
+
+// ICSEvent is one VEVENT. Floating events use local wall-clock times (no
+// timezone), which suits weekly classes; others are written in UTC.
+type ICSEvent struct {
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Floating    bool
+	RRule       string // e.g. "FREQ=WEEKLY;UNTIL=20261220T235959"
+}
+
+// BuildICS renders an RFC 5545 calendar with CRLF line endings and folded lines.
+func BuildICS(name string, events []ICSEvent) string {
+	var b strings.Builder
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//school-backend//timetable//EN")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	writeICSLine(&b, "X-WR-CALNAME:"+EscapeICSText(name))
+
+	for _, e := range events {
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, "UID:"+e.UID)
+		writeICSLine(&b, "DTSTAMP:"+stamp)
+		writeICSLine(&b, "DTSTART:"+ICSTime(e.Start, e.Floating))
+		writeICSLine(&b, "DTEND:"+ICSTime(e.End, e.Floating))
+		if e.RRule != "" {
+			writeICSLine(&b, "RRULE:"+e.RRule)
+		}
+		writeICSLine(&b, "SUMMARY:"+EscapeICSText(e.Summary))
+		if e.Location != "" {
+			writeICSLine(&b, "LOCATION:"+EscapeICSText(e.Location))
+		}
+		if e.Description != "" {
+			writeICSLine(&b, "DESCRIPTION:"+EscapeICSText(e.Description))
+		}
+		writeICSLine(&b, "END:VEVENT")
+	}
+
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// ICSTime formats a DATE-TIME value, in UTC unless floating.
+func ICSTime(t time.Time, floating bool) string {
+	if floating {
+		return t.Format("20060102T150405")
+	}
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// EscapeICSText escapes a TEXT property value.
+func EscapeICSText(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+// writeICSLine folds content lines longer than 75 octets, never splitting a
+// UTF-8 sequence.
+func writeICSLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -2,60 +2,71 @@ package utils
 
 import (
 	"fmt"
+	"school-backend/config"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+func jwtKey() []byte {
+    return []byte(config.App.JWTSecret)
+}
 
-var jwtKey = []byte("secret-key") // use env in prod
-
-func GenerateJWT(id , schoolID int, fullname, department,dbSlug, role string) string {
+// GenerateJWT mints a short-lived access token for the session sid; revoking
+// the session invalidates the token before it expires.
+func GenerateJWT(id , schoolID, sid int, fullname, department,dbSlug, role string) string {
     claims := jwt.MapClaims{
          "id":      id,
          "schoolID":schoolID,
+         "sid":     sid,
         "fullname": fullname,
         "department": department,
         "dbSlug":dbSlug,
         "role":     role,
-        "exp":      time.Now().Add(time.Hour * 72).Unix(),
+        "exp":      time.Now().Add(config.App.TokenTTL).Unix(),
     }
 	
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    tokenStr, _ := token.SignedString(jwtKey)
+    tokenStr, _ := token.SignedString(jwtKey())
     return tokenStr
 }
 
 func ValidateToken(tokenStr string) bool {
     _, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-        return jwtKey, nil
+        return jwtKey(), nil
     })
     return err == nil
 }
 
 
 
-func VerifyJWT(tokenStr string) (id, schoolID int, role, fullname, department, dbSlug string, err error) {
+func VerifyJWT(tokenStr string) (id, schoolID, sid int, role, fullname, department, dbSlug string, err error) {
     token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-        return jwtKey, nil
+        return jwtKey(), nil
     })
     if err != nil || !token.Valid {
-        return 0, 0, "", "", "", "", err
+        return 0, 0, 0, "", "", "", "", err
     }
 
     claims, ok := token.Claims.(jwt.MapClaims)
     if !ok {
-        return 0, 0, "", "", "", "", fmt.Errorf("invalid claims")
+        return 0, 0, 0, "", "", "", "", fmt.Errorf("invalid claims")
     }
 
     idFloat, ok := claims["id"].(float64)
     if !ok {
-        return 0, 0, "", "", "", "", fmt.Errorf("invalid id")
+        return 0, 0, 0, "", "", "", "", fmt.Errorf("invalid id")
     }
 
     schoolIDFloat, ok := claims["schoolID"].(float64)
     if !ok {
-        return 0, 0, "", "", "", "", fmt.Errorf("invalid schoolID")
+        return 0, 0, 0, "", "", "", "", fmt.Errorf("invalid schoolID")
+    }
+
+    // tokens from before sessions existed have no sid and can't be revoked
+    sidFloat, ok := claims["sid"].(float64)
+    if !ok {
+        return 0, 0, 0, "", "", "", "", fmt.Errorf("invalid sid")
     }
 
     roleStr, _ := claims["role"].(string)
@@ -63,5 +74,5 @@ func VerifyJWT(tokenStr string) (id, schoolID int, role, fullname, department, d
     departmentStr, _ := claims["department"].(string)
     dbSlugStr, _ := claims["dbSlug"].(string)
 
-    return int(idFloat), int(schoolIDFloat), roleStr, fullnameStr, departmentStr, dbSlugStr, nil
+    return int(idFloat), int(schoolIDFloat), int(sidFloat), roleStr, fullnameStr, departmentStr, dbSlugStr, nil
 }
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+)
+
+// RandomToken returns n random bytes hex-encoded, for secrets embedded in URLs.
+func RandomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// HashToken returns the SHA-256 of a token, hex-encoded. Secrets that are
+// only ever compared, like reset tokens, are stored this way.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// passwordAlphabet leaves out characters that are easy to misread on paper.
+const passwordAlphabet = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// RandomPassword returns an n-character initial password for handing out.
+func RandomPassword(n int) string {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for i := range b {
+		k, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic("crypto/rand failed: " + err.Error())
+		}
+		b[i] = passwordAlphabet[k.Int64()]
+	}
+	return string(b)
+}
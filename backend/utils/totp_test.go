@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA-1 test vectors from RFC 6238 Appendix B, cut to the last totpDigits
+// digits as an authenticator app configured with that many would show them.
+func TestTOTPCode(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // ASCII "12345678901234567890"
+	cases := []struct {
+		unix int64
+		want string // all 8 digits, as printed in the RFC
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tc := range cases {
+		want := tc.want[len(tc.want)-totpDigits:]
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(tc.unix, 0)))
+		if err != nil || got != want {
+			t.Errorf("TOTPCode at T=%d = %q, %v; want %q", tc.unix, got, err, want)
+		}
+	}
+}
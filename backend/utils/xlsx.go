@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// XLSXRow is one row of a worksheet. Number is the row as the spreadsheet
+// shows it, since empty rows are left out of the file.
+type XLSXRow struct {
+	Number int
+	Cells  []string
+}
+
+// ReadXLSX returns the cells of the first worksheet of an .xlsx file as rows
+// of text. It understands just enough of the format for plain tabular data:
+// shared and inline strings, numbers and booleans. Formulas give their
+// cached value.
+func ReadXLSX(data []byte) ([]XLSXRow, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx file: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := decodeZipXML(f, &sst); err != nil {
+			return nil, err
+		}
+		for _, si := range sst.Items {
+			shared = append(shared, si.String())
+		}
+	}
+
+	sheet, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	var ws struct {
+		Rows []struct {
+			Number int `xml:"r,attr"`
+			Cells  []struct {
+				Ref    string   `xml:"r,attr"`
+				Type   string   `xml:"t,attr"`
+				Value  string   `xml:"v"`
+				Inline xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeZipXML(files[sheet], &ws); err != nil {
+		return nil, err
+	}
+
+	rows := make([]XLSXRow, 0, len(ws.Rows))
+	for _, r := range ws.Rows {
+		number := r.Number
+		if number == 0 {
+			// r is optional and then means the row after the last one
+			number = 1
+			if len(rows) > 0 {
+				number = rows[len(rows)-1].Number + 1
+			}
+		}
+		var row []string
+		for i, cell := range r.Cells {
+			col := i
+			if cell.Ref != "" {
+				var err error
+				if col, err = xlsxColumn(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			var v string
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, fmt.Errorf("cell %s: bad shared string", cell.Ref)
+				}
+				v = shared[n]
+			case "inlineStr":
+				v = cell.Inline.String()
+			case "b":
+				v = map[string]string{"1": "TRUE", "0": "FALSE"}[cell.Value]
+			default:
+				v = cell.Value
+			}
+			for len(row) < col {
+				row = append(row, "")
+			}
+			row = append(row, v)
+		}
+		rows = append(rows, XLSXRow{Number: number, Cells: row})
+	}
+	return rows, nil
+}
+
+// xlsxText is a string item, either plain or split into rich-text runs.
+type xlsxText struct {
+	Text string   `xml:"t"`
+	Runs []string `xml:"r>t"`
+}
+
+func (t xlsxText) String() string {
+	return t.Text + strings.Join(t.Runs, "")
+}
+
+// firstSheetPath follows the workbook's relationships to the first sheet.
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var wb struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	wbFile, ok1 := files["xl/workbook.xml"]
+	relsFile, ok2 := files["xl/_rels/workbook.xml.rels"]
+	if !ok1 || !ok2 {
+		return "", errors.New("not an xlsx file: workbook missing")
+	}
+	if err := decodeZipXML(wbFile, &wb); err != nil {
+		return "", err
+	}
+	if err := decodeZipXML(relsFile, &rels); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", errors.New("workbook has no sheets")
+	}
+	for _, rel := range rels.Rels {
+		if rel.ID != wb.Sheets[0].RID {
+			continue
+		}
+		p := strings.TrimPrefix(rel.Target, "/")
+		if !strings.HasPrefix(p, "xl/") {
+			p = path.Join("xl", p)
+		}
+		if _, ok := files[p]; ok {
+			return p, nil
+		}
+	}
+	return "", errors.New("first sheet not found in workbook")
+}
+
+// xlsxMaxColumns is the widest sheet Excel allows, up to column XFD.
+const xlsxMaxColumns = 16384
+
+// xlsxColumn turns a cell reference like "AB12" into a zero-based column.
+// References past XFD are refused rather than padded out to.
+func xlsxColumn(ref string) (int, error) {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		if col > xlsxMaxColumns {
+			return 0, fmt.Errorf("cell %s: column out of range", ref)
+		}
+	}
+	if col == 0 {
+		return 0, fmt.Errorf("cell %s: bad reference", ref)
+	}
+	return col - 1, nil
+}
+
+func decodeZipXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// sheets are bounded by the upload limit, but a zip can expand a lot
+	return xml.NewDecoder(io.LimitReader(rc, 64<<20)).Decode(v)
+}
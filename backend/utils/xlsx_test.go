@@ -0,0 +1,26 @@
+package utils
+
+import "testing"
+
+func TestXLSXColumn(t *testing.T) {
+	cases := []struct {
+		ref  string
+		want int
+		ok   bool
+	}{
+		{"A1", 0, true},
+		{"Z9", 25, true},
+		{"AB12", 27, true},
+		{"XFD1", 16383, true},
+		{"XFE1", 0, false},
+		{"ZZZZZZZZZZZZZZZ1", 0, false},
+		{"12", 0, false},
+		{"", 0, false},
+	}
+	for _, tc := range cases {
+		got, err := xlsxColumn(tc.ref)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("xlsxColumn(%q) = %d, %v; want %d, ok %t", tc.ref, got, err, tc.want, tc.ok)
+		}
+	}
+}